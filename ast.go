@@ -0,0 +1,100 @@
+package scl
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	hcltoken "github.com/hashicorp/hcl/hcl/token"
+)
+
+// addNodes adds the items parsed from a single indented line of output to the
+// current block of the syntax tree, moving their positions to the SCL source
+// line.
+func (p *parser) addNodes(branch *scannerLine, f *ast.File) *ast.ObjectList {
+
+	relocateNodes(branch, f, len(p.indentation(p.indent)))
+
+	list, _ := f.Node.(*ast.ObjectList)
+
+	if list == nil {
+		return nil
+	}
+
+	current := p.lists[len(p.lists)-1]
+	current.Items = append(current.Items, list.Items...)
+	p.ast.Comments = append(p.ast.Comments, f.Comments...)
+
+	return list
+}
+
+// startNodeBlock adds a block parsed from a single line of output, and makes
+// it the current block until endNodeBlock is called.
+func (p *parser) startNodeBlock(branch *scannerLine, f *ast.File) error {
+
+	list, _ := f.Node.(*ast.ObjectList)
+
+	if list == nil || len(list.Items) == 0 {
+		return fmt.Errorf("Expected a block")
+	}
+
+	object, ok := list.Items[len(list.Items)-1].Val.(*ast.ObjectType)
+
+	if !ok {
+		return fmt.Errorf("Expected a block")
+	}
+
+	p.addNodes(branch, f)
+	p.lists = append(p.lists, object.List)
+
+	return nil
+}
+
+func (p *parser) endNodeBlock() {
+	if len(p.lists) > 1 {
+		p.lists = p.lists[:len(p.lists)-1]
+	}
+}
+
+func relocateNodes(branch *scannerLine, f *ast.File, indentation int) {
+
+	relocate := func(pos hcltoken.Pos) hcltoken.Pos {
+
+		if !pos.IsValid() {
+			return pos
+		}
+
+		return hcltoken.Pos{
+			Filename: branch.file,
+			Offset:   pos.Offset,
+			Line:     branch.line + pos.Line - 1,
+			Column:   branch.column + pos.Column - indentation,
+		}
+	}
+
+	ast.Walk(f.Node, func(n ast.Node) (ast.Node, bool) {
+
+		switch t := n.(type) {
+		case *ast.ObjectItem:
+			t.Assign = relocate(t.Assign)
+		case *ast.ObjectKey:
+			t.Token.Pos = relocate(t.Token.Pos)
+		case *ast.LiteralType:
+			t.Token.Pos = relocate(t.Token.Pos)
+		case *ast.ListType:
+			t.Lbrack = relocate(t.Lbrack)
+			t.Rbrack = relocate(t.Rbrack)
+		case *ast.ObjectType:
+			t.Lbrace = relocate(t.Lbrace)
+			t.Rbrace = relocate(t.Rbrace)
+		}
+
+		return n, true
+	})
+
+	// Lead and line comments are also in the file's list of comments
+	for _, group := range f.Comments {
+		for _, comment := range group.List {
+			comment.Start = relocate(comment.Start)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package scl
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserBuildsASyntaxTreeEquivalentToItsOutput(t *testing.T) {
+
+	fileNames, err := filepath.Glob("fixtures/valid/*.scl")
+	require.Nil(t, err)
+
+	for _, fileName := range fileNames {
+		t.Logf("File %s", fileName)
+
+		p := newMockParser(t)
+
+		if err := p.Parse(fileName); err != nil {
+			// Some fixtures need parameters
+			continue
+		}
+
+		var fromText, fromTree interface{}
+
+		textErr := hcl.Decode(&fromText, p.String())
+		treeErr := hcl.DecodeObject(&fromTree, p.AST())
+
+		require.Equal(t, textErr == nil, treeErr == nil)
+		require.Equal(t, fromText, fromTree)
+	}
+}
+
+func Test_ASyntaxTreeHasPositionsInTheSCLSource(t *testing.T) {
+
+	type position struct {
+		key    string
+		file   string
+		line   int
+		column int
+	}
+
+	for cycle, test := range []struct {
+		fileName  string
+		positions []position
+	}{
+		{
+			fileName: "fixtures/valid/mixin-declaration.scl",
+			positions: []position{
+				{"outer", "fixtures/valid/mixin-declaration.scl", 11, 5},
+				{"someLiteral", "fixtures/valid/mixin-declaration.scl", 12, 9},
+				{"wrapper", "fixtures/valid/mixin-declaration.scl", 4, 5},
+				{"someOtherLiteral", "fixtures/valid/mixin-declaration.scl", 5, 9},
+				{"nestedLiteral", "fixtures/valid/mixin-declaration.scl", 14, 13},
+				{"myCustomLiteral", "fixtures/valid/mixin-declaration.scl", 18, 5},
+				{"someArg", "fixtures/valid/mixin-declaration.scl", 10, 9},
+			},
+		},
+		{
+			fileName: "fixtures/valid/import.scl",
+			positions: []position{
+				{"wrapper", "fixtures/valid/basic.scl", 1, 1},
+				{"inner", "fixtures/valid/basic.scl", 2, 3},
+				{"another", "fixtures/valid/basic.scl", 3, 3},
+				{"yet_another", "fixtures/valid/basic.scl", 4, 4},
+				{"inner", "fixtures/valid/basic.scl", 5, 3},
+				{"output", "fixtures/valid/simple-mixin.scl", 2, 5},
+			},
+		},
+		{
+			fileName: "fixtures/valid/heredoc.scl",
+			positions: []position{
+				{"container", "fixtures/valid/heredoc.scl", 1, 1},
+				{"foo", "fixtures/valid/heredoc.scl", 2, 2},
+				{"bar", "fixtures/valid/heredoc.scl", 7, 2},
+			},
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		p := newMockParser(t)
+		require.Nil(t, p.Parse(test.fileName))
+
+		var positions []position
+
+		ast.Walk(p.AST(), func(n ast.Node) (ast.Node, bool) {
+			if item, ok := n.(*ast.ObjectItem); ok {
+				pos := item.Pos()
+				positions = append(positions, position{item.Keys[0].Token.Text, pos.Filename, pos.Line, pos.Column})
+			}
+			return n, true
+		})
+
+		require.Equal(t, test.positions, positions)
+	}
+}
@@ -0,0 +1,82 @@
+package scl
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	lastModified time.Time
+	lines        scannerTree
+}
+
+/*
+A Cache remembers the scanned contents of every SCL file read by the Parsers
+it is given to, keyed on the file's last modification time. When a Parser
+with a Cache reads a file whose modification time hasn't changed since it was
+last scanned, the file isn't re-read; the cached result is used instead.
+
+A Cache is intended for long-running programs that reload their configuration
+frequently, and may be shared between any number of Parsers, including
+concurrently. Files for which the FileSystem doesn't report a modification
+time are never cached.
+*/
+type Cache struct {
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+}
+
+/*
+NewCache creates a new, empty Cache.
+*/
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+/*
+Forget removes a single file from the cache, so that the next Parser to need
+it will read it again regardless of its modification time.
+*/
+func (c *Cache) Forget(fileName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, fileName)
+}
+
+/*
+Clear removes every file from the cache.
+*/
+func (c *Cache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[string]cacheEntry)
+}
+
+func (c *Cache) lines(fileName string, lastModified time.Time) (scannerTree, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[fileName]
+
+	if !ok || lastModified.IsZero() || !entry.lastModified.Equal(lastModified) {
+		return nil, false
+	}
+
+	return entry.lines, true
+}
+
+func (c *Cache) setLines(fileName string, lastModified time.Time, lines scannerTree) {
+
+	if lastModified.IsZero() {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[fileName] = cacheEntry{lastModified, lines}
+}
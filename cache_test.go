@@ -0,0 +1,219 @@
+package scl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryFile struct {
+	content      string
+	lastModified time.Time
+}
+
+type memoryFileSystem struct {
+	mutex sync.Mutex
+	files map[string]memoryFile
+	reads map[string]int
+}
+
+func newMemoryFileSystem() *memoryFileSystem {
+	return &memoryFileSystem{
+		files: make(map[string]memoryFile),
+		reads: make(map[string]int),
+	}
+}
+
+func (m *memoryFileSystem) set(name, content string, lastModified time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.files[name] = memoryFile{content, lastModified}
+}
+
+func (m *memoryFileSystem) remove(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.files, name)
+}
+
+func (m *memoryFileSystem) readCount(name string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.reads[name]
+}
+
+func (m *memoryFileSystem) Glob(pattern string) (out []string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for name := range m.files {
+		if ok, _ := path.Match(pattern, name); ok {
+			out = append(out, name)
+		}
+	}
+
+	sort.Strings(out)
+
+	return
+}
+
+func (m *memoryFileSystem) ReadCloser(name string) (io.ReadCloser, time.Time, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	f, ok := m.files[name]
+
+	if !ok {
+		return nil, time.Time{}, os.ErrNotExist
+	}
+
+	return &memoryReader{bytes.NewBufferString(f.content), name, m}, f.lastModified, nil
+}
+
+type memoryReader struct {
+	*bytes.Buffer
+	name string
+	fs   *memoryFileSystem
+}
+
+func (r *memoryReader) Read(b []byte) (int, error) {
+
+	if r.Buffer.Len() > 0 {
+		r.fs.mutex.Lock()
+		r.fs.reads[r.name]++
+		r.fs.mutex.Unlock()
+	}
+
+	return r.Buffer.Read(b)
+}
+
+func (r *memoryReader) Close() error {
+	return nil
+}
+
+func Test_ACacheAvoidsRescanningUnchangedFiles(t *testing.T) {
+
+	then := time.Date(2016, 11, 8, 15, 0, 0, 0, time.UTC)
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "include(\"lib\")\nmixin(1)", then)
+	fs.set("lib.scl", "@mixin($v)\n  value = $v", then)
+
+	cache := NewCache()
+
+	parse := func() Parser {
+		p, err := NewParser(fs)
+		require.Nil(t, err)
+		p.SetCache(cache)
+		require.Nil(t, p.Parse("main.scl"))
+		return p
+	}
+
+	p0 := parse()
+	require.Equal(t, "value = 1", p0.String())
+	require.Equal(t, 1, fs.readCount("main.scl"))
+	require.Equal(t, 1, fs.readCount("lib.scl"))
+
+	p1 := parse()
+	require.Equal(t, "value = 1", p1.String())
+	require.Equal(t, 1, fs.readCount("main.scl"))
+	require.Equal(t, 1, fs.readCount("lib.scl"))
+
+	fs.set("lib.scl", "@mixin($v)\n  other = $v", then.Add(time.Second))
+
+	p2 := parse()
+	require.Equal(t, "other = 1", p2.String())
+	require.Equal(t, 1, fs.readCount("main.scl"))
+	require.Equal(t, 2, fs.readCount("lib.scl"))
+
+	cache.Forget("main.scl")
+
+	parse()
+	require.Equal(t, 2, fs.readCount("main.scl"))
+	require.Equal(t, 2, fs.readCount("lib.scl"))
+}
+
+func Test_ACacheNeverStoresFilesWithoutAModificationTime(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "value = 1", time.Time{})
+
+	cache := NewCache()
+
+	for i := 1; i <= 2; i++ {
+		p, err := NewParser(fs)
+		require.Nil(t, err)
+		p.SetCache(cache)
+		require.Nil(t, p.Parse("main.scl"))
+		require.Equal(t, i, fs.readCount("main.scl"))
+	}
+}
+
+func Test_AParserReportsWhenItsOutputIsStale(t *testing.T) {
+
+	then := time.Date(2016, 11, 8, 15, 0, 0, 0, time.UTC)
+
+	for cycle, test := range []struct {
+		change func(fs *memoryFileSystem)
+		stale  bool
+	}{
+		{
+			change: func(fs *memoryFileSystem) {},
+			stale:  false,
+		},
+		{
+			change: func(fs *memoryFileSystem) {
+				fs.set("main.scl", "include(\"lib\")", then.Add(time.Minute))
+			},
+			stale: true,
+		},
+		{
+			change: func(fs *memoryFileSystem) {
+				fs.set("lib.scl", "value = 2", then.Add(time.Minute))
+			},
+			stale: true,
+		},
+		{
+			change: func(fs *memoryFileSystem) {
+				fs.remove("lib.scl")
+			},
+			stale: true,
+		},
+		{
+			change: func(fs *memoryFileSystem) {
+				fs.set("unrelated.scl", "value = 3", then.Add(time.Minute))
+			},
+			stale: false,
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		fs := newMemoryFileSystem()
+		fs.set("main.scl", "include(\"lib\")", then)
+		fs.set("lib.scl", "value = 1", then)
+
+		p, err := NewParser(fs)
+		require.Nil(t, err)
+		require.Nil(t, p.Parse("main.scl"))
+
+		stale, err := p.Stale()
+		require.Nil(t, err)
+		require.False(t, stale)
+
+		test.change(fs)
+
+		stale, err = p.Stale()
+		require.Nil(t, err)
+		require.Equal(t, test.stale, stale)
+	}
+}
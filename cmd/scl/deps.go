@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tucnak/climax"
+
+	"github.com/homemade/scl"
+)
+
+func depsCommand(stdout io.Writer, stderr io.Writer) climax.Command {
+
+	return climax.Command{
+		Name:  "deps",
+		Brief: "List the files included by one or more .scl files",
+		Usage: `[options] <filename.scl...>`,
+		Help: `Parse one or more .scl files and print every file they include, directly or
+indirectly. The default format is an indented tree showing the include path or
+vendor directory that satisfied each include; "list" prints each file once,
+suitable for Makefile dependencies, and "dot" prints a Graphviz digraph.`,
+
+		Flags: append(standardParserParams(), climax.Flag{
+			Name:     "format",
+			Short:    "f",
+			Usage:    `--format tree|list|dot`,
+			Help:     `The output format. Default is "tree".`,
+			Variable: true,
+		}),
+
+		Handle: func(ctx climax.Context) int {
+
+			if len(ctx.Args) == 0 {
+				fmt.Fprintf(stderr, "At least one filename is required. See `scl help deps` for syntax")
+				return 1
+			}
+
+			format := "tree"
+
+			if f, set := ctx.Get("format"); set {
+				format = f
+			}
+
+			var printDeps func(io.Writer, scl.Dependencies)
+
+			switch format {
+			case "tree":
+				printDeps = printDependencyTree
+			case "list":
+				printDeps = printDependencyList
+			case "dot":
+				printDeps = printDependencyGraph
+			default:
+				fmt.Fprintf(stderr, "Error: Unknown format %q. See `scl help deps` for syntax\n", format)
+				return 1
+			}
+
+			env, params, includePaths := parserParams(ctx)
+			params = append(env, params...)
+
+			parser, err := newParser(scl.NewDiskSystem(), params, includePaths)
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: Unable to create new parser in CWD: %s\n", err.Error())
+				return 1
+			}
+
+			for _, fileName := range ctx.Args {
+				if err := parser.Parse(fileName); err != nil {
+					fmt.Fprintf(stderr, "Error: Unable to parse file: %s\n", err.Error())
+					return 1
+				}
+			}
+
+			printDeps(stdout, parser.Dependencies())
+
+			return 0
+		},
+	}
+}
+
+func printDependencyTree(w io.Writer, deps scl.Dependencies) {
+
+	var walk func(deps scl.Dependencies, indentation int)
+
+	walk = func(deps scl.Dependencies, indentation int) {
+		for _, d := range deps {
+
+			line := strings.Repeat("  ", indentation) + d.File
+
+			switch {
+			case d.Vendored:
+				line += fmt.Sprintf(" (vendor %s, %s)", d.IncludePath, d.Reference)
+			case d.IncludePath != "":
+				line += fmt.Sprintf(" (include path %s, %s)", d.IncludePath, d.Reference)
+			case d.Reference != "":
+				line += fmt.Sprintf(" (%s)", d.Reference)
+			}
+
+			fmt.Fprintln(w, line)
+
+			walk(d.Children, indentation+1)
+		}
+	}
+
+	walk(deps, 0)
+}
+
+func printDependencyList(w io.Writer, deps scl.Dependencies) {
+	for _, f := range deps.Files() {
+		fmt.Fprintln(w, f)
+	}
+}
+
+func printDependencyGraph(w io.Writer, deps scl.Dependencies) {
+
+	seen := make(map[string]bool)
+
+	fmt.Fprintln(w, "digraph dependencies {")
+
+	var walk func(parent string, deps scl.Dependencies)
+
+	walk = func(parent string, deps scl.Dependencies) {
+		for _, d := range deps {
+
+			if parent == "" {
+				fmt.Fprintf(w, "  %q;\n", d.File)
+			} else {
+
+				label := ""
+
+				switch {
+				case d.Vendored:
+					label = ` [label="vendor"]`
+				case d.IncludePath != "":
+					label = fmt.Sprintf(" [label=%q]", d.IncludePath)
+				}
+
+				edge := fmt.Sprintf("  %q -> %q%s;", parent, d.File, label)
+
+				if seen[edge] {
+					continue
+				}
+
+				seen[edge] = true
+				fmt.Fprintln(w, edge)
+			}
+
+			walk(d.File, d.Children)
+		}
+	}
+
+	walk("", deps)
+
+	fmt.Fprintln(w, "}")
+}
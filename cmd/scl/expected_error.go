@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/homemade/scl"
+)
+
+/*
+expectedError is the content of an .err file, which says that the .scl file
+next to it must fail to parse. Blank lines and lines starting with # are
+ignored, and a line such as `line: 4` says which line of the .scl file the
+error must be reported at. The rest is text that the error must contain, or
+a regular expression that it must match if it's between slashes.
+*/
+type expectedError struct {
+	line    int
+	text    string
+	pattern *regexp.Regexp
+}
+
+// readExpectedError reads the .err file for an .scl file, returning nil if
+// there isn't one
+func readExpectedError(fs scl.FileSystem, fileName string) (*expectedError, error) {
+
+	errFile, _, err := fs.ReadCloser(strings.TrimSuffix(fileName, ".scl") + ".err")
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer errFile.Close()
+
+	content, err := ioutil.ReadAll(errFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseExpectedError(string(content))
+}
+
+func parseExpectedError(content string) (*expectedError, error) {
+
+	e := &expectedError{}
+	message := []string{}
+
+	for _, line := range strings.Split(content, "\n") {
+
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "", strings.HasPrefix(line, "#"):
+			// Ignore
+
+		case strings.HasPrefix(line, "line:"):
+
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "line:")))
+
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("Invalid line number: %s", line)
+			}
+
+			e.line = n
+
+		default:
+			message = append(message, line)
+		}
+	}
+
+	e.text = strings.Join(message, "\n")
+
+	if l := len(e.text); l > 1 && e.text[0] == '/' && e.text[l-1] == '/' {
+
+		pattern, err := regexp.Compile(e.text[1 : l-1])
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern %s: %s", e.text, err.Error())
+		}
+
+		e.text, e.pattern = "", pattern
+	}
+
+	return e, nil
+}
+
+// check returns why an error, or the lack of one, isn't what was expected,
+// or an empty string if it is
+func (e *expectedError) check(fileName string, err error) string {
+
+	if err == nil {
+		return "Expected an error, but the file parsed"
+	}
+
+	message := err.Error()
+
+	if e.pattern != nil && !e.pattern.MatchString(message) {
+		return fmt.Sprintf("Expected an error matching /%s/, got: %s", e.pattern, message)
+	}
+
+	if e.text != "" && !strings.Contains(message, e.text) {
+		return fmt.Sprintf("Expected an error containing %q, got: %s", e.text, message)
+	}
+
+	if e.line == 0 {
+		return ""
+	}
+
+	// Errors in includes are reported at the include as well as in the
+	// included file
+	for _, matches := range errorPositionMatcher.FindAllStringSubmatch(message, -1) {
+
+		if filepath.Clean(matches[1]) != filepath.Clean(fileName) {
+			continue
+		}
+
+		if line, _ := strconv.Atoi(matches[2]); line != e.line {
+			return fmt.Sprintf("Expected an error at line %d, got: %s", e.line, message)
+		}
+
+		return ""
+	}
+
+	return fmt.Sprintf("Expected an error at line %d, got: %s", e.line, message)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tucnak/climax"
+
+	"github.com/homemade/scl"
+)
+
+func explainCommand(stdout io.Writer, stderr io.Writer) climax.Command {
+
+	return climax.Command{
+		Name:  "explain",
+		Brief: "Show the mixin calls that produce the output of an .scl file",
+		Usage: `[options] <filename.scl>`,
+		Help: `Compile an .scl file and print the tree of mixin and __body__() calls it makes. Each call shows its
+arguments, the declaration it resolved to and the scope that declaration came from, and the lines of output it
+produced itself. With --block, only the calls that produced part of the given output block are shown.`,
+
+		Flags: append(standardParserParams(),
+			climax.Flag{
+				Name:     "block",
+				Short:    "b",
+				Usage:    `--block 'model "name"'`,
+				Help:     `Only show the calls that produced the output block with this header`,
+				Variable: true,
+			},
+		),
+
+		Handle: func(ctx climax.Context) int {
+
+			if len(ctx.Args) != 1 {
+				fmt.Fprintf(stderr, "Exactly one filename is required. See `scl help explain` for syntax")
+				return 1
+			}
+
+			tracer, err := scl.NewTracer(scl.NewDiskSystem())
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: Unable to create new tracer in CWD: %s\n", err.Error())
+				return 1
+			}
+
+			env, params, includePaths := parserParams(ctx)
+			params = append(env, params...)
+
+			for _, includeDir := range includePaths {
+				tracer.AddIncludePath(includeDir)
+			}
+
+			for _, p := range params {
+				tracer.SetParam(p.name, p.value)
+			}
+
+			trace, err := tracer.Trace(ctx.Args[0])
+
+			if trace == nil {
+				fmt.Fprintf(stderr, "Error: Unable to parse file: %s\n", err.Error())
+				return 1
+			}
+
+			first, last := 1, len(trace.Output)
+			calls := trace.Calls
+
+			if header, set := ctx.Get("block"); set {
+
+				var found bool
+
+				if first, last, found = trace.Block(header); !found {
+					fmt.Fprintf(stderr, "Error: No block %s in the output\n", header)
+					return 1
+				}
+
+				calls = calls.Within(first, last)
+			}
+
+			for _, call := range calls {
+				printCall(stdout, trace, call, "", first, last)
+			}
+
+			// The calls made before an error still explain the output so far
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: Unable to parse file: %s\n", err.Error())
+				return 1
+			}
+
+			return 0
+		},
+	}
+}
+
+// printCall prints a call and its children, with the output lines between
+// first and last that the call produced itself
+func printCall(w io.Writer, trace *scl.Trace, call *scl.Call, indentation string, first, last int) {
+
+	arguments := []string{}
+
+	for _, a := range call.Arguments {
+		arguments = append(arguments, fmt.Sprintf("$%s = %s", a.Name, a.Value))
+	}
+
+	fmt.Fprintf(w, "%s%s(%s) at %s\n", indentation, call.Mixin, strings.Join(arguments, ", "), call.Reference)
+
+	switch {
+	case call.Mixin == "__body__":
+		fmt.Fprintf(w, "%s  body of the call at %s\n", indentation, call.Declaration)
+	case call.Scope == nil:
+		fmt.Fprintf(w, "%s  declared at %s, in the root scope\n", indentation, call.Declaration)
+	default:
+		fmt.Fprintf(w, "%s  declared at %s, in the scope of %s() at %s\n", indentation, call.Declaration, call.Scope.Mixin, call.Scope.Reference)
+	}
+
+	printLines := func(from, to int) {
+		for line := from; line <= to; line++ {
+			if line >= first && line <= last {
+				fmt.Fprintf(w, "%s  %4d | %s\n", indentation, line, trace.Output[line-1])
+			}
+		}
+	}
+
+	line := call.FirstLine
+
+	for _, child := range call.Children {
+
+		if child.FirstLine > 0 {
+			printLines(line, child.FirstLine-1)
+			line = child.LastLine + 1
+		}
+
+		printCall(w, trace, child, indentation+"  ", first, last)
+	}
+
+	if call.FirstLine > 0 {
+		printLines(line, call.LastLine)
+	}
+}
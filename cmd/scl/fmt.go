@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aryann/difflib"
+	"github.com/tucnak/climax"
+
+	"github.com/homemade/scl"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+func fmtCommand(stdin io.Reader, stdout io.Writer, stderr io.Writer) climax.Command {
+
+	return climax.Command{
+		Name:  "fmt",
+		Brief: "Format .scl files",
+		Usage: `[options] [<filename.scl|directory>...]`,
+		Help: `Format one or more .scl files in the canonical style, printing the result to stdout. Directories are
+searched for .scl files recursively. With no files, stdin is formatted.`,
+
+		Flags: []climax.Flag{
+			{
+				Name:  "write",
+				Short: "w",
+				Usage: `--write`,
+				Help:  `Write the result back to each file rather than to stdout, if it has changed`,
+			},
+			{
+				Name:  "list",
+				Short: "l",
+				Usage: `--list`,
+				Help:  `List the files whose formatting differs, rather than printing them`,
+			},
+			{
+				Name:  "diff",
+				Short: "d",
+				Usage: `--diff`,
+				Help:  `Print a diff of the changes formatting would make, rather than the files`,
+			},
+		},
+
+		Handle: func(ctx climax.Context) int {
+
+			write, list, diff := ctx.Is("write"), ctx.Is("list"), ctx.Is("diff")
+
+			if len(ctx.Args) == 0 {
+
+				if write {
+					fmt.Fprintf(stderr, "Error: Can't write to stdin\n")
+					return 1
+				}
+
+				if err := formatFile(stdin, stdout, "<stdin>", false, list, diff); err != nil {
+					fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+					return 1
+				}
+
+				return 0
+			}
+
+			fileNames, err := sclFiles(ctx.Args)
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+				return 1
+			}
+
+			errors := 0
+
+			for _, fileName := range fileNames {
+
+				if err := formatFile(nil, stdout, fileName, write, list, diff); err != nil {
+					fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+					errors++
+				}
+			}
+
+			if errors > 0 {
+				return 1
+			}
+
+			return 0
+		},
+	}
+}
+
+// sclFiles expands any directories to the .scl files they contain
+func sclFiles(args []string) (fileNames []string, err error) {
+
+	for _, arg := range args {
+
+		info, err := os.Stat(arg)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			fileNames = append(fileNames, arg)
+			continue
+		}
+
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() && filepath.Ext(path) == ".scl" {
+				fileNames = append(fileNames, path)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// formatFile formats a file, or the reader if it isn't nil
+func formatFile(in io.Reader, stdout io.Writer, fileName string, write, list, diff bool) error {
+
+	var (
+		src []byte
+		err error
+	)
+
+	if in != nil {
+		src, err = ioutil.ReadAll(in)
+	} else {
+		src, err = ioutil.ReadFile(fileName)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	formatted, err := scl.Format(src)
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", fileName, err.Error())
+	}
+
+	changed := !bytes.Equal(src, formatted)
+
+	if list && changed {
+		fmt.Fprintln(stdout, fileName)
+	}
+
+	if diff && changed {
+		printFormatDiff(stdout, fileName, src, formatted)
+	}
+
+	if write && changed {
+
+		info, err := os.Stat(fileName)
+
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(fileName, formatted, info.Mode()); err != nil {
+			return err
+		}
+	}
+
+	if !write && !list && !diff {
+		stdout.Write(formatted)
+	}
+
+	return nil
+}
+
+// printFormatDiff prints the changed lines of a file, with a few lines of
+// context around each change
+func printFormatDiff(stdout io.Writer, fileName string, src, formatted []byte) {
+
+	lines := func(b []byte) []string {
+		return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	}
+
+	diff := difflib.Diff(lines(src), lines(formatted))
+
+	fmt.Fprintf(stdout, "--- %s\n+++ %s (formatted)\n", fileName, fileName)
+
+	// Each line is shown if it's within the context of a change
+	show := make([]bool, len(diff))
+
+	for i, d := range diff {
+		if d.Delta != difflib.Common {
+			for j := i - diffContext; j <= i+diffContext; j++ {
+				if j >= 0 && j < len(diff) {
+					show[j] = true
+				}
+			}
+		}
+	}
+
+	for i, d := range diff {
+
+		if !show[i] {
+			continue
+		}
+
+		if i > 0 && !show[i-1] {
+			fmt.Fprintln(stdout, "...")
+		}
+
+		fmt.Fprintln(stdout, d.String())
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tucnak/climax"
+
+	"github.com/homemade/scl"
+)
+
+func lintCommand(stdout io.Writer, stderr io.Writer) climax.Command {
+
+	rules := []string{}
+
+	for _, rule := range scl.LintRules {
+		rules = append(rules, string(rule))
+	}
+
+	return climax.Command{
+		Name:  "lint",
+		Brief: "Check one or more .scl files for likely mistakes",
+		Usage: `[options] <filename.scl|directory>...`,
+		Help: `Compile one or more .scl files and report problems that aren't errors, but probably aren't what was
+meant, such as unused mixins and variables. Directories are searched for .scl files recursively. Every rule is
+enabled by default. The rules are: ` + strings.Join(rules, ", ") + `.`,
+
+		Flags: append(standardParserParams(),
+			climax.Flag{
+				Name:     "enable",
+				Usage:    `--enable unused-mixin,unused-param`,
+				Help:     `Comma-separated list of the only rules to check`,
+				Variable: true,
+			},
+			climax.Flag{
+				Name:     "disable",
+				Usage:    `--disable shadowed-variable`,
+				Help:     `Comma-separated list of rules not to check`,
+				Variable: true,
+			},
+		),
+
+		Handle: func(ctx climax.Context) int {
+
+			if len(ctx.Args) == 0 {
+				fmt.Fprintf(stderr, "At least one filename is required. See `scl help lint` for syntax")
+				return 1
+			}
+
+			enabled, err := lintRules(ctx, "enable")
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+				return 1
+			}
+
+			disabled, err := lintRules(ctx, "disable")
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+				return 1
+			}
+
+			fileNames, err := sclFiles(ctx.Args)
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+				return 1
+			}
+
+			env, params, includePaths := parserParams(ctx)
+			params = append(env, params...)
+			problems := 0
+
+			for _, fileName := range fileNames {
+
+				linter, err := scl.NewLinter(scl.NewDiskSystem())
+
+				if err != nil {
+					fmt.Fprintf(stderr, "Error: Unable to create new linter in CWD: %s\n", err.Error())
+					return 1
+				}
+
+				for _, includeDir := range includePaths {
+					linter.AddIncludePath(includeDir)
+				}
+
+				for _, p := range params {
+					linter.SetParam(p.name, p.value)
+				}
+
+				if enabled != nil {
+					linter.Disable(scl.LintRules...)
+					linter.Enable(enabled...)
+				}
+
+				linter.Disable(disabled...)
+
+				issues, err := linter.Lint(fileName)
+
+				for _, issue := range issues {
+					fmt.Fprintln(stdout, issue.String())
+					problems++
+				}
+
+				if err != nil {
+					fmt.Fprintf(stderr, "Error: Unable to parse file: %s\n", err.Error())
+					problems++
+				}
+			}
+
+			if problems > 0 {
+				return 1
+			}
+
+			return 0
+		},
+	}
+}
+
+// lintRules reads a comma-separated list of rules from a flag, returning nil
+// if the flag isn't set
+func lintRules(ctx climax.Context, flag string) ([]scl.LintRule, error) {
+
+	value, set := ctx.Get(flag)
+
+	if !set {
+		return nil, nil
+	}
+
+	rules := []scl.LintRule{}
+
+	for _, name := range strings.Split(value, ",") {
+
+		rule := scl.LintRule(strings.TrimSpace(name))
+		known := false
+
+		for _, r := range scl.LintRules {
+			if r == rule {
+				known = true
+			}
+		}
+
+		if !known {
+			return nil, fmt.Errorf("Unknown lint rule %q. See `scl help lint` for the rules", rule)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
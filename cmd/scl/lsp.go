@@ -0,0 +1,769 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tucnak/climax"
+
+	"github.com/homemade/scl"
+)
+
+// Error codes and enumerations from the Language Server Protocol
+const (
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspCompletionFunction = 3
+	lspCompletionVariable = 6
+
+	lspSyncFull = 1
+)
+
+var errorPositionMatcher = regexp.MustCompile(`\[([^\[\]]+):(\d+)\] ?`)
+
+func lspCommand(stdin io.Reader, stdout io.Writer, stderr io.Writer) climax.Command {
+
+	return climax.Command{
+		Name:  "lsp",
+		Brief: "Run a Language Server Protocol server for SCL over stdio",
+		Usage: `[options]`,
+		Help: `Serve the Language Server Protocol on stdin and stdout, for editors. The server reports errors and lint
+issues as diagnostics, finds the declarations and uses of mixins and variables across includes, shows the
+signature and docs of mixins on hover, completes the mixins and variables in scope and formats documents.
+Include paths are relative to the workspace root.`,
+
+		Flags: standardParserParams(),
+
+		Handle: func(ctx climax.Context) int {
+
+			env, params, includePaths := parserParams(ctx)
+			params = append(env, params...)
+
+			server := &lspServer{
+				out:          stdout,
+				log:          stderr,
+				params:       params,
+				includePaths: includePaths,
+				fs:           &documentFileSystem{FileSystem: scl.NewDiskSystem(), documents: make(map[string]string)},
+			}
+
+			return server.serve(bufio.NewReader(stdin))
+		},
+	}
+}
+
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+	Context  struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label         string `json:"label"`
+	Kind          int    `json:"kind"`
+	Detail        string `json:"detail,omitempty"`
+	Documentation string `json:"documentation,omitempty"`
+	FilterText    string `json:"filterText,omitempty"`
+	InsertText    string `json:"insertText,omitempty"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+// documentFileSystem reads documents that are open in the editor from memory,
+// so that unsaved changes are compiled, and everything else from disk. Files
+// are named relative to the workspace root, if they're inside it.
+type documentFileSystem struct {
+	scl.FileSystem
+	root      string
+	documents map[string]string
+}
+
+// resolve returns the path on disk of a file named relative to the root
+func (d *documentFileSystem) resolve(path string) string {
+
+	if d.root == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(d.root, path)
+}
+
+// name returns the name of a file on disk relative to the root, if it's
+// inside it
+func (d *documentFileSystem) name(path string) string {
+
+	root := d.root
+
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+
+	if rel, err := filepath.Rel(root, path); err == nil && filepath.IsAbs(path) && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+
+	return filepath.Clean(path)
+}
+
+func (d *documentFileSystem) Glob(pattern string) ([]string, error) {
+
+	matches, err := d.FileSystem.Glob(d.resolve(pattern))
+
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+
+	for i, match := range matches {
+
+		if !filepath.IsAbs(pattern) {
+			matches[i] = d.name(match)
+		}
+
+		found[filepath.Clean(matches[i])] = true
+	}
+
+	for name := range d.documents {
+		if ok, _ := filepath.Match(filepath.Clean(pattern), name); ok && !found[name] {
+			matches = append(matches, name)
+		}
+	}
+
+	return matches, nil
+}
+
+func (d *documentFileSystem) ReadCloser(path string) (io.ReadCloser, time.Time, error) {
+
+	if text, ok := d.documents[filepath.Clean(path)]; ok {
+		return ioutil.NopCloser(strings.NewReader(text)), time.Now(), nil
+	}
+
+	return d.FileSystem.ReadCloser(d.resolve(path))
+}
+
+type lspServer struct {
+	out          io.Writer
+	log          io.Writer
+	params       paramSlice
+	includePaths []string
+	fs           *documentFileSystem
+	shutdown     bool
+}
+
+func (s *lspServer) serve(in *bufio.Reader) int {
+
+	for {
+
+		body, err := readLSPMessage(in)
+
+		if err == io.EOF {
+			return 1
+		}
+
+		if err != nil {
+			fmt.Fprintf(s.log, "Error: %s\n", err.Error())
+			return 1
+		}
+
+		var msg lspMessage
+
+		if err := json.Unmarshal(body, &msg); err != nil {
+			fmt.Fprintf(s.log, "Error: Invalid message: %s\n", err.Error())
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, rpcErr := s.handle(msg)
+
+		// Notifications have no ID, and get no response
+		if msg.ID == nil {
+			if rpcErr != nil {
+				fmt.Fprintf(s.log, "Error: %s: %s\n", msg.Method, rpcErr.Message)
+			}
+			continue
+		}
+
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+
+		if rpcErr != nil {
+			response["error"] = rpcErr
+		} else {
+			response["result"] = result
+		}
+
+		s.send(response)
+	}
+}
+
+func readLSPMessage(in *bufio.Reader) ([]byte, error) {
+
+	length := -1
+
+	for {
+
+		header, err := in.ReadString('\n')
+
+		if err != nil {
+			return nil, err
+		}
+
+		header = strings.TrimSpace(header)
+
+		if header == "" {
+			break
+		}
+
+		if value := strings.TrimPrefix(header, "Content-Length:"); value != header {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("Invalid Content-Length header: %s", header)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("Missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+
+	if _, err := io.ReadFull(in, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func (s *lspServer) send(msg interface{}) {
+
+	body, err := json.Marshal(msg)
+
+	if err != nil {
+		fmt.Fprintf(s.log, "Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *lspServer) notify(method string, params interface{}) {
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *lspServer) handle(msg lspMessage) (interface{}, *lspError) {
+
+	switch msg.Method {
+
+	case "initialize":
+		return s.initialize(msg.Params)
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+
+		s.fs.documents[s.path(params.TextDocument.URI)] = params.TextDocument.Text
+		s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didChange":
+
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+
+		// Documents are always synchronised in full
+		if l := len(params.ContentChanges); l > 0 {
+			s.fs.documents[s.path(params.TextDocument.URI)] = params.ContentChanges[l-1].Text
+		}
+
+		s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didSave":
+
+		var params lspDocumentPosition
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+
+		s.publishDiagnostics(params.TextDocument.URI)
+
+	case "textDocument/didClose":
+
+		var params lspDocumentPosition
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+
+		delete(s.fs.documents, s.path(params.TextDocument.URI))
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         params.TextDocument.URI,
+			"diagnostics": []lspDiagnostic{},
+		})
+
+	case "textDocument/definition", "textDocument/references", "textDocument/hover", "textDocument/completion":
+
+		var params lspDocumentPosition
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+
+		return s.query(msg.Method, params), nil
+
+	case "textDocument/formatting":
+
+		var params lspDocumentPosition
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+
+		return s.format(s.path(params.TextDocument.URI)), nil
+
+	default:
+		if msg.ID != nil && !strings.HasPrefix(msg.Method, "$/") {
+			return nil, &lspError{lspMethodNotFound, fmt.Sprintf("Unsupported method %s", msg.Method)}
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *lspServer) initialize(raw json.RawMessage) (interface{}, *lspError) {
+
+	var params struct {
+		RootURI  string `json:"rootUri"`
+		RootPath string `json:"rootPath"`
+	}
+
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &lspError{lspInvalidParams, err.Error()}
+	}
+
+	// Includes are relative to the root, as they are for scl run in the root
+	// of a project
+	root := params.RootPath
+
+	if params.RootURI != "" {
+		root = uriFilePath(params.RootURI)
+	}
+
+	if root != "" {
+		if abs, err := filepath.Abs(root); err == nil {
+			s.fs.root = abs
+		}
+	}
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           lspSyncFull,
+			"definitionProvider":         true,
+			"referencesProvider":         true,
+			"hoverProvider":              true,
+			"documentFormattingProvider": true,
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"$"},
+			},
+		},
+		"serverInfo": map[string]string{"name": "scl"},
+	}, nil
+}
+
+func (s *lspServer) publishDiagnostics(uri string) {
+
+	path := s.path(uri)
+	diagnostics := []lspDiagnostic{}
+
+	linter, err := scl.NewLinter(s.fs)
+
+	if err != nil {
+		fmt.Fprintf(s.log, "Error: %s\n", err.Error())
+		return
+	}
+
+	for _, includeDir := range s.includePaths {
+		linter.AddIncludePath(includeDir)
+	}
+
+	for _, p := range s.params {
+		linter.SetParam(p.name, p.value)
+	}
+
+	issues, err := linter.Lint(path)
+
+	if err != nil {
+
+		line, message := 1, err.Error()
+
+		// Errors in includes are reported at the include in this document
+		for _, matches := range errorPositionMatcher.FindAllStringSubmatch(message, -1) {
+			if filepath.Clean(matches[1]) == path {
+				line, _ = strconv.Atoi(matches[2])
+				message = strings.Replace(message, matches[0], "", 1)
+				break
+			}
+		}
+
+		diagnostics = append(diagnostics, lspDiagnostic{s.lineRange(path, line), lspSeverityError, "scl", message})
+	}
+
+	for _, issue := range issues {
+		if filepath.Clean(issue.File) == path {
+			diagnostics = append(diagnostics, lspDiagnostic{s.lineRange(path, issue.Line), lspSeverityWarning, "scl lint", fmt.Sprintf("%s (%s)", issue.Message, issue.Rule)})
+		}
+	}
+
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+func (s *lspServer) index(path string) *scl.Index {
+
+	indexer, err := scl.NewIndexer(s.fs)
+
+	if err != nil {
+		fmt.Fprintf(s.log, "Error: %s\n", err.Error())
+		return &scl.Index{}
+	}
+
+	for _, includeDir := range s.includePaths {
+		indexer.AddIncludePath(includeDir)
+	}
+
+	for _, p := range s.params {
+		indexer.SetParam(p.name, p.value)
+	}
+
+	// The symbols found before any error are still useful while editing
+	index, _ := indexer.Index(path)
+
+	if index == nil {
+		return &scl.Index{}
+	}
+
+	return index
+}
+
+func (s *lspServer) query(method string, params lspDocumentPosition) interface{} {
+
+	path := s.path(params.TextDocument.URI)
+	index := s.index(path)
+
+	line := params.Position.Line + 1
+	text := s.line(path, line)
+	column := byteColumn(text, params.Position.Character)
+
+	switch method {
+
+	case "textDocument/definition":
+
+		if symbol := index.At(path, line, column); symbol != nil {
+			return s.location(symbol.Declaration, symbol.Name)
+		}
+
+	case "textDocument/references":
+
+		symbol := index.At(path, line, column)
+
+		if symbol == nil {
+			return nil
+		}
+
+		locations := []lspLocation{}
+
+		if params.Context.IncludeDeclaration {
+			locations = append(locations, s.location(symbol.Declaration, symbol.Name))
+		}
+
+		for _, ref := range symbol.References {
+			locations = append(locations, s.location(ref, symbol.Name))
+		}
+
+		return locations
+
+	case "textDocument/hover":
+
+		symbol := index.At(path, line, column)
+
+		if symbol == nil {
+			return nil
+		}
+
+		value := "```scl\n" + symbol.Signature + "\n```"
+
+		if symbol.Docs != "" {
+			value += "\n\n" + symbol.Docs
+		}
+
+		return map[string]interface{}{
+			"contents": map[string]string{"kind": "markdown", "value": value},
+		}
+
+	case "textDocument/completion":
+
+		dollar := column > 0 && column <= len(text) && text[column-1] == '$'
+		items := []lspCompletionItem{}
+		seen := map[string]bool{}
+
+		for _, symbol := range index.Visible(path, line) {
+
+			if seen[fmt.Sprint(symbol.Kind, symbol.Name)] {
+				continue
+			}
+
+			seen[fmt.Sprint(symbol.Kind, symbol.Name)] = true
+
+			if symbol.Kind == scl.MixinSymbol {
+				items = append(items, lspCompletionItem{
+					Label:         symbol.Name,
+					Kind:          lspCompletionFunction,
+					Detail:        symbol.Signature,
+					Documentation: symbol.Docs,
+				})
+				continue
+			}
+
+			item := lspCompletionItem{
+				Label:      "$" + symbol.Name,
+				Kind:       lspCompletionVariable,
+				Detail:     symbol.Signature,
+				FilterText: symbol.Name,
+				InsertText: "$" + symbol.Name,
+			}
+
+			if dollar {
+				item.InsertText = symbol.Name
+			}
+
+			items = append(items, item)
+		}
+
+		return items
+	}
+
+	return nil
+}
+
+func (s *lspServer) format(path string) interface{} {
+
+	src, ok := s.fs.documents[path]
+
+	if !ok {
+		return nil
+	}
+
+	formatted, err := scl.Format([]byte(src))
+
+	if err != nil {
+		fmt.Fprintf(s.log, "Error: %s: %s\n", path, err.Error())
+		return nil
+	}
+
+	if string(formatted) == src {
+		return []lspTextEdit{}
+	}
+
+	end := lspPosition{Line: strings.Count(src, "\n") + 1}
+
+	return []lspTextEdit{{lspRange{lspPosition{}, end}, string(formatted)}}
+}
+
+// line returns a line of a file, counting from one
+func (s *lspServer) line(path string, line int) string {
+
+	text, ok := s.fs.documents[path]
+
+	if !ok {
+		content, err := ioutil.ReadFile(s.fs.resolve(path))
+
+		if err != nil {
+			return ""
+		}
+
+		text = string(content)
+	}
+
+	lines := strings.Split(text, "\n")
+
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	return strings.TrimSuffix(lines[line-1], "\r")
+}
+
+func (s *lspServer) lineRange(path string, line int) lspRange {
+
+	text := s.line(path, line)
+	indent := len(text) - len(strings.TrimLeft(text, " \t"))
+
+	return lspRange{
+		Start: lspPosition{line - 1, utf16Column(text, indent)},
+		End:   lspPosition{line - 1, utf16Column(text, len(text))},
+	}
+}
+
+func (s *lspServer) location(pos scl.Position, name string) lspLocation {
+
+	text := s.line(pos.File, pos.Line)
+
+	return lspLocation{
+		URI: s.uri(pos.File),
+		Range: lspRange{
+			Start: lspPosition{pos.Line - 1, utf16Column(text, pos.Column)},
+			End:   lspPosition{pos.Line - 1, utf16Column(text, pos.Column+len(name))},
+		},
+	}
+}
+
+// path converts a file URI to the name the parser uses for it, which is
+// relative to the workspace root if the file is inside it
+func (s *lspServer) path(uri string) string {
+	return s.fs.name(uriFilePath(uri))
+}
+
+func (s *lspServer) uri(path string) string {
+
+	path = s.fs.resolve(path)
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func uriFilePath(uri string) string {
+
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return filepath.FromSlash(u.Path)
+	}
+
+	return uri
+}
+
+// utf16Column converts a byte column to the UTF-16 columns of the protocol
+func utf16Column(line string, column int) int {
+
+	if column > len(line) {
+		column = len(line)
+	}
+
+	units := 0
+
+	for _, r := range line[:column] {
+		units++
+
+		if r >= 0x10000 {
+			units++
+		}
+	}
+
+	return units
+}
+
+// byteColumn converts a UTF-16 column of the protocol to a byte column
+func byteColumn(line string, character int) int {
+
+	units := 0
+
+	for i, r := range line {
+
+		if units >= character {
+			return i
+		}
+
+		units++
+
+		if r >= 0x10000 {
+			units++
+		}
+	}
+
+	return len(line)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/homemade/scl"
+)
+
+func newTestLSPServer(t *testing.T, files map[string]string) (*lspServer, *bytes.Buffer, string) {
+
+	root, err := ioutil.TempDir("", "scl-lsp")
+	require.NoError(t, err)
+
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644))
+	}
+
+	out := &bytes.Buffer{}
+
+	s := &lspServer{
+		out: out,
+		log: ioutil.Discard,
+		fs:  &documentFileSystem{FileSystem: scl.NewDiskSystem(), documents: make(map[string]string)},
+	}
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	_, rpcErr := s.handle(lspMessage{Method: "initialize", Params: json.RawMessage(`{"rootUri": "file://` + filepath.ToSlash(root) + `"}`)})
+	require.Nil(t, rpcErr)
+
+	after, err := os.Getwd()
+	require.NoError(t, err)
+	require.Equal(t, wd, after, "The server shouldn't change the working directory")
+
+	return s, out, root
+}
+
+// lspMessages reads the messages a server has sent
+func lspMessages(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+
+	messages := []map[string]interface{}{}
+	in := bufio.NewReader(out)
+
+	for {
+		body, err := readLSPMessage(in)
+
+		if err != nil {
+			return messages
+		}
+
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &msg))
+
+		messages = append(messages, msg)
+	}
+}
+
+func lspDocumentMessage(method, uri, extra string) lspMessage {
+	return lspMessage{Method: method, Params: json.RawMessage(`{"textDocument": {"uri": "` + uri + `"` + extra + `}}`)}
+}
+
+func Test_TheLSPServerPublishesDiagnostics(t *testing.T) {
+
+	for cycle, test := range []struct {
+		text     string
+		expected []interface{}
+	}{
+		{
+			text:     "include(\"lib.scl\")\nhelper()",
+			expected: []interface{}{},
+		},
+		{
+			text: "include(\"lib.scl\")\nport = $missing",
+			expected: []interface{}{
+				map[string]interface{}{
+					"range":    map[string]interface{}{"start": map[string]interface{}{"line": 1.0, "character": 0.0}, "end": map[string]interface{}{"line": 1.0, "character": 15.0}},
+					"severity": 1.0,
+					"source":   "scl",
+					"message":  "Unknown variable '$missing'",
+				},
+			},
+		},
+		{
+			text: "include(\"lib.scl\")\n$unused = 1",
+			expected: []interface{}{
+				map[string]interface{}{
+					"range":    map[string]interface{}{"start": map[string]interface{}{"line": 1.0, "character": 0.0}, "end": map[string]interface{}{"line": 1.0, "character": 11.0}},
+					"severity": 2.0,
+					"source":   "scl lint",
+					"message":  "Variable $unused is declared but never used (unused-variable)",
+				},
+			},
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		s, out, root := newTestLSPServer(t, map[string]string{"lib.scl": "@helper()\n    a = 1"})
+		defer os.RemoveAll(root)
+
+		uri := "file://" + filepath.ToSlash(filepath.Join(root, "main.scl"))
+		text, _ := json.Marshal(test.text)
+
+		_, rpcErr := s.handle(lspDocumentMessage("textDocument/didOpen", uri, `, "text": `+string(text)))
+		require.Nil(t, rpcErr)
+
+		messages := lspMessages(t, out)
+		require.Len(t, messages, 1)
+		require.Equal(t, "textDocument/publishDiagnostics", messages[0]["method"])
+
+		params := messages[0]["params"].(map[string]interface{})
+		require.Equal(t, uri, params["uri"])
+		require.Equal(t, test.expected, params["diagnostics"])
+	}
+}
+
+func Test_TheLSPServerFindsDefinitions(t *testing.T) {
+
+	s, out, root := newTestLSPServer(t, map[string]string{
+		"lib.scl":  "@helper($port)\n    port = $port",
+		"main.scl": "include(\"lib.scl\")\n$p = 80\nhelper($p)",
+	})
+	defer os.RemoveAll(root)
+
+	uri := "file://" + filepath.ToSlash(filepath.Join(root, "main.scl"))
+	lib := "file://" + filepath.ToSlash(filepath.Join(root, "lib.scl"))
+
+	for cycle, test := range []struct {
+		line, character int
+		expected        interface{}
+	}{
+		{
+			line: 2, character: 1,
+			expected: lspLocation{lib, lspRange{lspPosition{0, 1}, lspPosition{0, 7}}},
+		},
+		{
+			line: 2, character: 8,
+			expected: lspLocation{uri, lspRange{lspPosition{1, 1}, lspPosition{1, 2}}},
+		},
+		{
+			line: 0, character: 0,
+			expected: nil,
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		position, _ := json.Marshal(lspPosition{test.line, test.character})
+
+		result, rpcErr := s.handle(lspMessage{
+			Method: "textDocument/definition",
+			Params: json.RawMessage(`{"textDocument": {"uri": "` + uri + `"}, "position": ` + string(position) + `}`),
+		})
+
+		require.Nil(t, rpcErr)
+		require.Equal(t, test.expected, result)
+	}
+
+	require.Empty(t, lspMessages(t, out))
+}
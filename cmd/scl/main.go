@@ -1,17 +1,16 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Masterminds/vcs"
-	"github.com/aryann/difflib"
 	"github.com/tucnak/climax"
 
 	"github.com/homemade/scl"
@@ -25,6 +24,12 @@ func main() {
 	app.AddCommand(getCommand(os.Stdout, os.Stderr))
 	app.AddCommand(runCommand(os.Stdout, os.Stderr))
 	app.AddCommand(testCommand(os.Stdout, os.Stderr))
+	app.AddCommand(depsCommand(os.Stdout, os.Stderr))
+	app.AddCommand(fmtCommand(os.Stdin, os.Stdout, os.Stderr))
+	app.AddCommand(lintCommand(os.Stdout, os.Stderr))
+	app.AddCommand(lspCommand(os.Stdin, os.Stdout, os.Stderr))
+	app.AddCommand(replCommand(os.Stdin, os.Stdout, os.Stderr))
+	app.AddCommand(explainCommand(os.Stdout, os.Stderr))
 
 	os.Exit(app.Run())
 }
@@ -35,9 +40,86 @@ func runCommand(stdout io.Writer, stderr io.Writer) climax.Command {
 		Name:  "run",
 		Brief: "Transform one or more .scl files into HCL",
 		Usage: `[options] <filename.scl...>`,
-		Help:  `Transform one or more .scl files into HCL. Output is written to stdout.`,
-
-		Flags: standardParserParams(),
+		Help: `Transform one or more .scl files into HCL, or into HCL2, JSON or YAML with --format.
+Output is written to stdout, or to the file given by --output. The JSON of more than one file is a single object
+keyed by file name. In watch mode, every file the .scl files include is
+checked for changes, and the output is regenerated whenever one changes.`,
+
+		Flags: append(standardParserParams(),
+			climax.Flag{
+				Name:     "format",
+				Short:    "f",
+				Usage:    `--format hcl|hcl2|json|yaml`,
+				Help:     `The output format. Default is "hcl".`,
+				Variable: true,
+			},
+			climax.Flag{
+				Name:  "validate",
+				Usage: `--validate`,
+				Help:  `Check HCL2 output with the HCL2 parser, reporting any errors against the .scl source`,
+			},
+			climax.Flag{
+				Name:     "output",
+				Short:    "o",
+				Usage:    `--output /path/to/output.hcl`,
+				Help:     `Write the output to a file rather than stdout. The file is only written if its content has changed.`,
+				Variable: true,
+			},
+			climax.Flag{
+				Name:  "comments",
+				Usage: `--comments`,
+				Help:  `Write // comments and docblocks from the .scl files to the output`,
+			},
+			climax.Flag{
+				Name:  "no-mixin-comments",
+				Usage: `--no-mixin-comments`,
+				Help:  `With --comments, leave out comments from inside mixin declarations`,
+			},
+			climax.Flag{
+				Name:     "indent",
+				Usage:    `--indent 4`,
+				Help:     `The number of spaces for each level of indentation. Default is 2.`,
+				Variable: true,
+			},
+			climax.Flag{
+				Name:  "tabs",
+				Usage: `--tabs`,
+				Help:  `Indent with tabs rather than spaces`,
+			},
+			climax.Flag{
+				Name:  "blank-lines",
+				Usage: `--blank-lines`,
+				Help:  `Separate top-level blocks with blank lines`,
+			},
+			climax.Flag{
+				Name:  "align",
+				Usage: `--align`,
+				Help:  `Align the equals signs of consecutive attributes`,
+			},
+			climax.Flag{
+				Name:     "header",
+				Usage:    `--header "Generated by scl; do not edit"`,
+				Help:     `A comment to write at the top of each file's output`,
+				Variable: true,
+			},
+			climax.Flag{
+				Name:  "no-banner",
+				Usage: `--no-banner`,
+				Help:  `Don't write the /* filename */ banner before each file's HCL`,
+			},
+			climax.Flag{
+				Name:  "watch",
+				Short: "w",
+				Usage: `--watch`,
+				Help:  `Recompile whenever any of the files or their includes change`,
+			},
+			climax.Flag{
+				Name:     "interval",
+				Usage:    `--interval 500ms`,
+				Help:     `How often to check for changes in watch mode. Default is 1s.`,
+				Variable: true,
+			},
+		),
 
 		Handle: func(ctx climax.Context) int {
 
@@ -46,31 +128,136 @@ func runCommand(stdout io.Writer, stderr io.Writer) climax.Command {
 				return 1
 			}
 
-			params, includePaths := parserParams(ctx)
+			env, params, includePaths := parserParams(ctx)
+			params = append(env, params...)
+			outputPath, _ := ctx.Get("output")
+			format := "hcl"
+			validate := ctx.Is("validate")
+			commentMode := scl.NoComments
 
-			for _, fileName := range ctx.Args {
+			if ctx.Is("comments") {
+				commentMode = scl.AllComments
 
-				parser, err := scl.NewParser(scl.NewDiskSystem())
+				if ctx.Is("no-mixin-comments") {
+					commentMode = scl.NoMixinComments
+				}
+			}
 
-				if err != nil {
-					fmt.Fprintf(stderr, "Error: Unable to create new parser in CWD: %s\n", err.Error())
-					return 1
+			if f, set := ctx.Get("format"); set {
+				format = f
+			}
+
+			outputFormat, err := parseOutputFormat(ctx)
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+				return 1
+			}
+
+			banner := !ctx.Is("no-banner")
+
+			if !validOutputFormat(format) {
+				fmt.Fprintf(stderr, "Error: Unknown format %q. See `scl help run` for syntax\n", format)
+				return 1
+			}
+
+			// The JSON of more than one file is written as a single object,
+			// keyed by file name, so that the output is still valid JSON
+			keyed := format == "json" && len(ctx.Args) > 1
+
+			compile := func(cache *scl.Cache) (output string, parsers []scl.Parser, err error) {
+
+				documents := []string{}
+
+				if keyed {
+					defer func() {
+						output = jsonObject(ctx.Args[:len(documents)], documents)
+					}()
 				}
 
-				for _, includeDir := range includePaths {
-					parser.AddIncludePath(includeDir)
+				for _, fileName := range ctx.Args {
+
+					parser, err := newParser(scl.NewDiskSystem(), params, includePaths)
+
+					if err != nil {
+						return output, parsers, fmt.Errorf("Unable to create new parser in CWD: %s", err.Error())
+					}
+
+					if cache != nil {
+						parser.SetCache(cache)
+					}
+
+					parser.SetCommentMode(commentMode)
+					parser.SetOutputFormat(outputFormat)
+					parser.SetDebugWriter(stderr)
+					parsers = append(parsers, parser)
+
+					err = parser.Parse(fileName)
+
+					for _, warning := range parser.Warnings() {
+						fmt.Fprintf(stderr, "Warning: %s\n", warning)
+					}
+
+					if err != nil {
+						return output, parsers, fmt.Errorf("Unable to parse file: %s%s", err.Error(), missingParams(parser))
+					}
+
+					formatted, err := formatOutput(parser, fileName, format, validate, banner)
+
+					if err != nil {
+						return output, parsers, fmt.Errorf("Unable to format output for %s: %s", fileName, err.Error())
+					}
+
+					if keyed {
+						documents = append(documents, formatted)
+					} else {
+						output += formatted
+					}
 				}
 
-				for _, p := range params {
-					parser.SetParam(p.name, p.value)
+				return
+			}
+
+			if ctx.Is("watch") {
+
+				interval := time.Second
+
+				if i, set := ctx.Get("interval"); set {
+
+					d, err := time.ParseDuration(i)
+
+					if err != nil {
+						fmt.Fprintf(stderr, "Error: Invalid interval: %s\n", err.Error())
+						return 1
+					}
+
+					if d <= 0 {
+						fmt.Fprintf(stderr, "Error: Invalid interval: %s must be greater than zero\n", i)
+						return 1
+					}
+
+					interval = d
 				}
 
-				if err := parser.Parse(fileName); err != nil {
-					fmt.Fprintf(stderr, "Error: Unable to parse file: %s\n", err.Error())
-					return 1
+				return watch(stdout, stderr, interval, outputPath, compile)
+			}
+
+			output, _, err := compile(nil)
+
+			if err != nil {
+
+				// Files compiled before the error are still printed
+				if outputPath == "" {
+					fmt.Fprint(stdout, output)
 				}
 
-				fmt.Fprintf(stdout, "/* %s */\n%s\n\n", fileName, parser)
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+				return 1
+			}
+
+			if _, err := writeOutput(stdout, outputPath, output); err != nil {
+				fmt.Fprintf(stderr, "Error: Unable to write output: %s\n", err.Error())
+				return 1
 			}
 
 			return 0
@@ -190,158 +377,194 @@ func getCommand(stdout io.Writer, stderr io.Writer) climax.Command {
 	}
 }
 
-func testCommand(stdout io.Writer, stderr io.Writer) climax.Command {
+func standardParserParams() []climax.Flag {
 
-	return climax.Command{
-		Name:  "test",
-		Brief: "Parse each .scl file in a directory and compare the output to an .hcl file",
-		Usage: `[options] [file-glob...]`,
-		Help:  "Parse each .scl file in a directory and compare the output to an .hcl file",
+	return []climax.Flag{
+		{
+			Name:     "include",
+			Short:    "i",
+			Usage:    `--include /path/to/lib1,/path/to/lib2`,
+			Help:     `Comma-separated list of include paths`,
+			Variable: true,
+		},
+		{
+			Name:     "param",
+			Short:    "p",
+			Usage:    `--param param0=somthing,"param1='something else'"`,
+			Help:     `Comma-separated list of include paths`,
+			Variable: true,
+		},
+		{
+			Name:  "no-env",
+			Short: "ne",
+			Usage: `--no-env`,
+			Help:  `Don't import envionment variables when parsing the SCL`,
+		},
+	}
 
-		Flags: standardParserParams(),
+}
 
-		Handle: func(ctx climax.Context) int {
+// parserParams returns the params imported from the environment, those given
+// with --param, which take precedence, and the include paths
+func parserParams(ctx climax.Context) (env, params paramSlice, includePaths []string) {
 
-			errors := 0
+	env = environmentParams(ctx)
 
-			reportError := func(path string, err string, args ...interface{}) {
-				fmt.Fprintf(stderr, "%-7s %s %s\n", "FAIL", path, fmt.Sprintf(err, args...))
-				errors++
-			}
+	if ps, set := ctx.Get("param"); set {
+		for _, p := range strings.Split(ps, ",") {
+			params.Set(p)
+		}
+	}
 
-			if len(ctx.Args) == 0 {
-				fmt.Fprintf(stderr, "At least one file glob is required. See `sep help test` for syntax")
-				return 1
-			}
+	if ps, set := ctx.Get("include"); set {
+		for _, i := range strings.Split(ps, ",") {
+			includePaths = append(includePaths, i)
+		}
+	}
 
-			newlineMatcher := regexp.MustCompile("\n\n")
-			params, includePaths := parserParams(ctx)
+	return
+}
 
-			for _, fileName := range ctx.Args {
+// environmentParams imports every environment variable as a param, unless
+// --no-env is given
+func environmentParams(ctx climax.Context) (params paramSlice) {
 
-				fs := scl.NewDiskSystem()
-				parser, err := scl.NewParser(fs)
-				now := time.Now()
+	if !ctx.Is("no-env") {
+		for _, envVar := range os.Environ() {
+			params.Set(envVar)
+		}
+	}
 
-				if err != nil {
-					reportError("Unable to create new parser in CWD: %s", err.Error())
-					continue
-				}
+	return
+}
 
-				for _, includeDir := range includePaths {
-					parser.AddIncludePath(includeDir)
-				}
+// missingParams lists the required params that a parser wasn't given
+func missingParams(parser scl.Parser) string {
 
-				for _, p := range params {
-					parser.SetParam(p.name, p.value)
-				}
+	missing := parser.Params().Missing()
 
-				if err := parser.Parse(fileName); err != nil {
-					reportError(fileName, "Unable to parse file: %s", err.Error())
-					continue
-				}
+	if len(missing) == 0 {
+		return ""
+	}
 
-				hclFilePath := strings.TrimSuffix(fileName, ".scl") + ".hcl"
-				hclFile, _, err := fs.ReadCloser(hclFilePath)
+	lines := []string{"\n\nMissing params, which can be set with --param name=value or environment variables:"}
 
-				if err != nil {
-					fmt.Fprintf(stdout, "%-7s %s [no .hcl file]\n", "?", fileName)
-					continue
-				}
+	for _, param := range missing {
+		lines = append(lines, fmt.Sprintf("  %s at %s", param, param.Reference))
+	}
 
-				hcl, err := ioutil.ReadAll(hclFile)
+	return strings.Join(lines, "\n")
+}
 
-				if err != nil {
-					reportError(fileName, "Unable to read .hcl file: %s", err.Error())
-					continue
-				}
+func newParser(fs scl.FileSystem, params paramSlice, includePaths []string) (scl.Parser, error) {
 
-				hclLines := strings.Split(strings.TrimSuffix(newlineMatcher.ReplaceAllString(string(hcl), "\n"), "\n"), "\n")
-				sclLines := strings.Split(parser.String(), "\n")
+	parser, err := scl.NewParser(fs)
 
-				diff := difflib.Diff(hclLines, sclLines)
+	if err != nil {
+		return nil, err
+	}
 
-				success := true
+	for _, includeDir := range includePaths {
+		parser.AddIncludePath(includeDir)
+	}
 
-				for _, d := range diff {
-					if d.Delta != difflib.Common {
-						success = false
-					}
-				}
+	for _, p := range params {
+		parser.SetParam(p.name, p.value)
+	}
 
-				if !success {
-					reportError(fileName, "Diff failed:")
+	return parser, nil
+}
 
-					fmt.Fprintln(stderr)
+func validOutputFormat(format string) bool {
+	switch format {
+	case "hcl", "hcl2", "json", "yaml":
+		return true
+	}
 
-					for _, d := range diff {
-						fmt.Fprintf(stderr, "\t%s\n", d.String())
-					}
+	return false
+}
 
-					fmt.Fprintln(stderr)
+func parseOutputFormat(ctx climax.Context) (format scl.OutputFormat, err error) {
 
-					continue
-				}
+	if i, set := ctx.Get("indent"); set {
 
-				fmt.Fprintf(stdout, "%-7s %s\t%.3fs\n", "ok", fileName, time.Since(now).Seconds())
-			}
+		if format.IndentWidth, err = strconv.Atoi(i); err != nil || format.IndentWidth < 1 {
+			return format, fmt.Errorf("Invalid indent %q", i)
+		}
+	}
 
-			if errors > 0 {
-				fmt.Fprintf(stderr, "\n[FAIL] %d error(s)\n", errors)
-				return 1
-			}
+	format.Tabs = ctx.Is("tabs")
+	format.BlankLines = ctx.Is("blank-lines")
+	format.AlignEquals = ctx.Is("align")
+	format.Header, _ = ctx.Get("header")
 
-			return 0
-		},
-	}
+	return format, nil
 }
 
-func standardParserParams() []climax.Flag {
+func formatOutput(parser scl.Parser, fileName, format string, validate, banner bool) (string, error) {
 
-	return []climax.Flag{
-		{
-			Name:     "include",
-			Short:    "i",
-			Usage:    `--include /path/to/lib1,/path/to/lib2`,
-			Help:     `Comma-separated list of include paths`,
-			Variable: true,
-		},
-		{
-			Name:     "param",
-			Short:    "p",
-			Usage:    `--param param0=somthing,"param1='something else'"`,
-			Help:     `Comma-separated list of include paths`,
-			Variable: true,
-		},
-		{
-			Name:  "no-env",
-			Short: "ne",
-			Usage: `--no-env`,
-			Help:  `Don't import envionment variables when parsing the SCL`,
-		},
+	switch format {
+	case "hcl2":
+
+		hcl2, err := parser.HCL2(validate)
+
+		if err != nil {
+			return "", err
+		}
+
+		return addBanner(fileName, string(hcl2)+"\n", banner), nil
+
+	case "json":
+
+		document, err := parser.JSON()
+
+		if err != nil {
+			return "", err
+		}
+
+		return string(document) + "\n", nil
+
+	case "yaml":
+
+		yaml, err := parser.YAML()
+
+		if err != nil {
+			return "", err
+		}
+
+		// Each file is a separate document
+		return "---\n" + string(yaml), nil
 	}
 
+	return addBanner(fileName, parser.String()+"\n\n", banner), nil
 }
 
-func parserParams(ctx climax.Context) (params paramSlice, includePaths []string) {
+// jsonObject joins the JSON documents of several files into one object, keyed
+// by file name in the order the files were given
+func jsonObject(fileNames, documents []string) string {
 
-	if !ctx.Is("no-env") {
-		for _, envVar := range os.Environ() {
-			params.Set(envVar)
-		}
+	entries := []string{}
+
+	for i, document := range documents {
+
+		key, _ := json.Marshal(fileNames[i])
+		document = strings.Replace(strings.TrimSpace(document), "\n", "\n  ", -1)
+
+		entries = append(entries, fmt.Sprintf("  %s: %s", key, document))
 	}
 
-	if ps, set := ctx.Get("param"); set {
-		for _, p := range strings.Split(ps, ",") {
-			params.Set(p)
-		}
+	if len(entries) == 0 {
+		return "{}\n"
 	}
 
-	if ps, set := ctx.Get("include"); set {
-		for _, i := range strings.Split(ps, ",") {
-			includePaths = append(includePaths, i)
-		}
+	return "{\n" + strings.Join(entries, ",\n") + "\n}\n"
+}
+
+func addBanner(fileName, output string, banner bool) string {
+
+	if !banner {
+		return output
 	}
 
-	return
+	return fmt.Sprintf("/* %s */\n%s", fileName, output)
 }
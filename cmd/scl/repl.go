@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tucnak/climax"
+
+	"github.com/homemade/scl"
+)
+
+const (
+	replPrompt             = "scl> "
+	replContinuationPrompt = "...  "
+)
+
+var (
+	replBlockStartMatcher = regexp.MustCompile(`^/\*|(\{|<<-?\w+|\\)$`)
+	replSingleLineMatcher = regexp.MustCompile(`^((\$?[\w.-]+|"[^"]*")\s*[:?]?=|include\(|#|//)`)
+)
+
+const replHelp = `Enter SCL to see the HCL it produces. Variables and mixins stay in scope for later entries.
+An entry continues over the indented lines that follow it, such as the body of a block, a mixin declaration or
+a mixin call, until a blank line or a line that isn't indented. Assignments, includes and comments are compiled
+straight away. An entry that starts a docblock, or any line that ends with {, a heredoc or \, continues until a
+blank line.
+
+Commands:
+  :vars              List the variables in scope, apart from the environment
+  :mixins            List the mixins in scope
+  :include <file>... Include one or more files
+  :param name=value  Set a param
+  :output            Show all of the HCL produced so far
+  :reset             Forget every variable, mixin and output, apart from params
+  :help              Show this help
+  :quit              Leave the REPL
+`
+
+func replCommand(stdin io.Reader, stdout io.Writer, stderr io.Writer) climax.Command {
+
+	return climax.Command{
+		Name:  "repl",
+		Brief: "Experiment with SCL interactively",
+		Usage: `[options]`,
+		Help: `Start an interactive session that compiles each entry of SCL as it's typed and prints the HCL it produces.
+Type :help in the session for its commands.`,
+
+		Flags: standardParserParams(),
+
+		Handle: func(ctx climax.Context) int {
+
+			session, err := scl.NewSession(scl.NewDiskSystem())
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: Unable to create new session in CWD: %s\n", err.Error())
+				return 1
+			}
+
+			env, params, includePaths := parserParams(ctx)
+
+			for _, includeDir := range includePaths {
+				session.AddIncludePath(includeDir)
+			}
+
+			// The environment is kept out of :vars
+			for _, p := range env {
+				session.SetEnv(p.name, p.value)
+			}
+
+			for _, p := range params {
+				session.SetParam(p.name, p.value)
+			}
+
+			fmt.Fprintln(stdout, "SCL REPL. Type :help for commands, :quit to leave.")
+
+			runREPL(session, bufio.NewScanner(stdin), stdout, stderr)
+
+			return 0
+		},
+	}
+}
+
+func runREPL(session scl.Session, in *bufio.Scanner, stdout io.Writer, stderr io.Writer) {
+
+	// A line that isn't indented ends an entry, and starts the next
+	pending, hasPending := "", false
+
+	for {
+
+		var line string
+
+		if hasPending {
+			line, hasPending = pending, false
+		} else {
+
+			fmt.Fprint(stdout, replPrompt)
+
+			if !in.Scan() {
+				fmt.Fprintln(stdout)
+				return
+			}
+
+			line = in.Text()
+		}
+
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, ":") {
+
+			if !replCommandLine(session, trimmed, stdout, stderr) {
+				return
+			}
+
+			continue
+		}
+
+		entry := []string{strings.TrimSuffix(line, `\`)}
+		untilBlank := replBlockStartMatcher.MatchString(trimmed)
+
+		if untilBlank || !replSingleLineMatcher.MatchString(trimmed) {
+			for {
+
+				fmt.Fprint(stdout, replContinuationPrompt)
+
+				if !in.Scan() || strings.TrimSpace(in.Text()) == "" {
+					break
+				}
+
+				next := in.Text()
+
+				if !untilBlank && next[0] != ' ' && next[0] != '\t' {
+					pending, hasPending = next, true
+					break
+				}
+
+				// Heredocs and braces may be followed by lines that aren't
+				// indented
+				untilBlank = untilBlank || replBlockStartMatcher.MatchString(strings.TrimSpace(next))
+
+				entry = append(entry, strings.TrimSuffix(next, `\`))
+			}
+		}
+
+		output, err := session.Eval(strings.Join(entry, "\n"))
+
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+			continue
+		}
+
+		if output != "" {
+			fmt.Fprintln(stdout, output)
+		}
+	}
+}
+
+// replCommandLine runs a : command, returning false if the REPL should stop
+func replCommandLine(session scl.Session, line string, stdout io.Writer, stderr io.Writer) bool {
+
+	fields := strings.Fields(line)
+	args := fields[1:]
+
+	switch fields[0] {
+
+	case ":quit", ":q", ":exit":
+		return false
+
+	case ":help", ":h":
+		fmt.Fprint(stdout, replHelp)
+
+	case ":vars":
+		printSorted(stdout, session.Variables(), func(name, value string) string {
+			return fmt.Sprintf("$%s = %s", name, value)
+		})
+
+	case ":mixins":
+		printSorted(stdout, session.Mixins(), func(name, signature string) string {
+			return signature
+		})
+
+	case ":include":
+
+		if len(args) == 0 {
+			fmt.Fprintf(stderr, "Error: At least one filename is required\n")
+			break
+		}
+
+		for _, fileName := range args {
+
+			output, err := session.Eval(fmt.Sprintf("include(%q)", fileName))
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+				break
+			}
+
+			if output != "" {
+				fmt.Fprintln(stdout, output)
+			}
+		}
+
+	case ":param":
+
+		var params paramSlice
+
+		if len(args) == 0 {
+			fmt.Fprintf(stderr, "Error: Expected name=value\n")
+			break
+		}
+
+		for _, arg := range args {
+			if err := params.Set(arg); err != nil {
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+			}
+		}
+
+		for _, p := range params {
+			session.SetParam(p.name, p.value)
+		}
+
+	case ":output":
+		if output := session.String(); output != "" {
+			fmt.Fprintln(stdout, output)
+		}
+
+	case ":reset":
+		session.Reset()
+
+	default:
+		fmt.Fprintf(stderr, "Error: Unknown command %s. Type :help for commands\n", fields[0])
+	}
+
+	return true
+}
+
+func printSorted(w io.Writer, values map[string]string, format func(name, value string) string) {
+
+	names := []string{}
+
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintln(w, format(name, values[name]))
+	}
+}
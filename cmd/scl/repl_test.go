@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/homemade/scl"
+)
+
+func Test_TheREPLReadsIndentedEntries(t *testing.T) {
+
+	for cycle, test := range []struct {
+		input  string
+		output []string
+	}{
+		{
+			input:  "service \"web\"\n    port = 80\n",
+			output: []string{"service \"web\" {\n  port = 80\n}"},
+		},
+		{
+			input:  "@m($p)\n    port = $p\nm(80)\n",
+			output: []string{"port = 80"},
+		},
+		{
+			input:  "@wrap()\n    block\n        __body__()\n\nwrap()\n    a = 1\nb = 2\n",
+			output: []string{"block {\n  a = 1\n}", "b = 2"},
+		},
+		{
+			input:  "a = 1\nb = 2\n",
+			output: []string{"a = 1", "b = 2"},
+		},
+		{
+			input:  "block\n    text = <<EOT\nhello\nEOT\n\n",
+			output: []string{"block {\n  text = <<EOT\nhello\nEOT\n}"},
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		session, err := scl.NewSession(scl.NewDiskSystem())
+		require.NoError(t, err)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		runREPL(session, bufio.NewScanner(strings.NewReader(test.input)), stdout, stderr)
+
+		require.Empty(t, stderr.String())
+
+		output := []string{}
+
+		for _, line := range strings.Split(stdout.String(), "\n") {
+
+			// Prompts are written before each line is read
+			for strings.HasPrefix(line, replPrompt) || strings.HasPrefix(line, replContinuationPrompt) {
+				line = strings.TrimPrefix(strings.TrimPrefix(line, replPrompt), replContinuationPrompt)
+			}
+
+			if line != "" {
+				output = append(output, line)
+			}
+		}
+
+		require.Equal(t, strings.Split(strings.Join(test.output, "\n"), "\n"), output)
+	}
+}
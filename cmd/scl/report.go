@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// testReportWriter returns the function that writes test results in a
+// report format, or nil if the format isn't known
+func testReportWriter(format string) func(io.Writer, []testResult) error {
+
+	switch format {
+	case "junit":
+		return writeJUnitReport
+	case "tap":
+		return writeTAPReport
+	case "json":
+		return writeJSONReport
+	}
+
+	return nil
+}
+
+// writeTestReport writes a report to a file, or to stdout if there's no path
+func writeTestReport(stdout io.Writer, path string, write func(io.Writer, []testResult) error, results []testResult) error {
+
+	if path == "" {
+		return write(stdout, results)
+	}
+
+	f, err := os.Create(path)
+
+	if err != nil {
+		return err
+	}
+
+	err = write(f, results)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// reportStatus is the status of a result in a report; .hcl files that were
+// created or updated count as passes
+func reportStatus(result testResult) string {
+
+	switch result.status {
+	case testCreated, testUpdated:
+		return testPassed
+	}
+
+	return result.status
+}
+
+// reportMessage is the message of a result in a report
+func reportMessage(result testResult) string {
+
+	switch result.status {
+	case testCreated, testUpdated:
+		return fmt.Sprintf("%s %s", result.status, hclFileName(result.file))
+	}
+
+	return result.message
+}
+
+type jsonReport struct {
+	Tests   []jsonReportTest `json:"tests"`
+	Passed  int              `json:"passed"`
+	Failed  int              `json:"failed"`
+	Skipped int              `json:"skipped"`
+}
+
+type jsonReportTest struct {
+	File     string   `json:"file"`
+	Status   string   `json:"status"`
+	Duration float64  `json:"duration"`
+	Message  string   `json:"message,omitempty"`
+	Details  []string `json:"details,omitempty"`
+}
+
+func writeJSONReport(w io.Writer, results []testResult) error {
+
+	report := jsonReport{Tests: []jsonReportTest{}}
+
+	for _, result := range results {
+
+		status := reportStatus(result)
+
+		switch status {
+		case testPassed:
+			report.Passed++
+		case testFailed:
+			report.Failed++
+		case testSkipped:
+			report.Skipped++
+		}
+
+		report.Tests = append(report.Tests, jsonReportTest{
+			File:     result.file,
+			Status:   status,
+			Duration: result.duration.Seconds(),
+			Message:  reportMessage(result),
+			Details:  result.details,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
+
+func writeTAPReport(w io.Writer, results []testResult) error {
+
+	lines := []string{"TAP version 13", fmt.Sprintf("1..%d", len(results))}
+
+	for i, result := range results {
+
+		message := reportMessage(result)
+
+		switch reportStatus(result) {
+
+		case testSkipped:
+			lines = append(lines, fmt.Sprintf("ok %d - %s # SKIP %s", i+1, result.file, message))
+
+		case testFailed:
+			lines = append(lines,
+				fmt.Sprintf("not ok %d - %s", i+1, result.file),
+				"  ---",
+				fmt.Sprintf("  message: %q", message),
+				fmt.Sprintf("  duration_ms: %.3f", result.duration.Seconds()*1000),
+			)
+
+			if len(result.details) > 0 {
+
+				lines = append(lines, "  diff: |")
+
+				for _, d := range result.details {
+					lines = append(lines, "    "+d)
+				}
+			}
+
+			lines = append(lines, "  ...")
+
+		default:
+			lines = append(lines, fmt.Sprintf("ok %d - %s", i+1, result.file))
+		}
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+
+	return err
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",cdata"`
+}
+
+func writeJUnitReport(w io.Writer, results []testResult) error {
+
+	suite := junitTestSuite{Name: "scl test", Tests: len(results)}
+	total := 0.0
+
+	for _, result := range results {
+
+		total += result.duration.Seconds()
+
+		c := junitTestCase{
+			Name:      result.file,
+			ClassName: "scl",
+			Time:      fmt.Sprintf("%.3f", result.duration.Seconds()),
+		}
+
+		message := reportMessage(result)
+
+		switch reportStatus(result) {
+
+		case testFailed:
+			c.Failure = &junitMessage{Message: message, Body: strings.Join(result.details, "\n")}
+			suite.Failures++
+
+		case testSkipped:
+			c.Skipped = &junitMessage{Message: message}
+			suite.Skipped++
+
+		default:
+			c.SystemOut = message
+		}
+
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	suite.Time = fmt.Sprintf("%.3f", total)
+
+	output, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, output)
+
+	return err
+}
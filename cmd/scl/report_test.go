@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TestResultsCanBeReported(t *testing.T) {
+
+	results := []testResult{
+		{file: "a.scl", status: testPassed, duration: 1500 * time.Millisecond},
+		{file: "b.scl", status: testFailed, duration: 250 * time.Millisecond, message: `Output differs: format = "%s"`, details: []string{"a: 1 != 2", "b: (missing) != 3"}},
+		{file: "c.scl", status: testSkipped, message: "no .hcl file"},
+		{file: "d.scl", status: testCreated, duration: time.Millisecond},
+	}
+
+	for cycle, test := range []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "json",
+			expected: `{
+  "tests": [
+    {
+      "file": "a.scl",
+      "status": "pass",
+      "duration": 1.5
+    },
+    {
+      "file": "b.scl",
+      "status": "fail",
+      "duration": 0.25,
+      "message": "Output differs: format = \"%s\"",
+      "details": [
+        "a: 1 != 2",
+        "b: (missing) != 3"
+      ]
+    },
+    {
+      "file": "c.scl",
+      "status": "skip",
+      "duration": 0,
+      "message": "no .hcl file"
+    },
+    {
+      "file": "d.scl",
+      "status": "pass",
+      "duration": 0.001,
+      "message": "created d.hcl"
+    }
+  ],
+  "passed": 2,
+  "failed": 1,
+  "skipped": 1
+}
+`,
+		},
+		{
+			format: "tap",
+			expected: `TAP version 13
+1..4
+ok 1 - a.scl
+not ok 2 - b.scl
+  ---
+  message: "Output differs: format = \"%s\""
+  duration_ms: 250.000
+  diff: |
+    a: 1 != 2
+    b: (missing) != 3
+  ...
+ok 3 - c.scl # SKIP no .hcl file
+ok 4 - d.scl
+`,
+		},
+		{
+			format: "junit",
+			expected: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="scl test" tests="4" failures="1" skipped="1" time="1.751">
+    <testcase name="a.scl" classname="scl" time="1.500"></testcase>
+    <testcase name="b.scl" classname="scl" time="0.250">
+      <failure message="Output differs: format = &#34;%s&#34;"><![CDATA[a: 1 != 2
+b: (missing) != 3]]></failure>
+    </testcase>
+    <testcase name="c.scl" classname="scl" time="0.000">
+      <skipped message="no .hcl file"></skipped>
+    </testcase>
+    <testcase name="d.scl" classname="scl" time="0.001">
+      <system-out>created d.hcl</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`,
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		write := testReportWriter(test.format)
+		require.NotNil(t, write)
+
+		out := &bytes.Buffer{}
+		require.NoError(t, writeTestReport(out, "", write, results))
+		require.Equal(t, test.expected, out.String())
+	}
+
+	require.Nil(t, testReportWriter("xml"))
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aryann/difflib"
+	"github.com/tucnak/climax"
+
+	"github.com/homemade/scl"
+)
+
+var testBlockMatcher = regexp.MustCompile(`(?m)^\s*@test\s`)
+
+// The outcomes of testing a file
+const (
+	testPassed  = "pass"
+	testFailed  = "fail"
+	testSkipped = "skip"
+	testCreated = "created"
+	testUpdated = "updated"
+)
+
+// testResult is the outcome of testing one .scl file. Details are the lines
+// of a diff or of semantic differences.
+type testResult struct {
+	file     string
+	status   string
+	duration time.Duration
+	message  string
+	details  []string
+}
+
+// testOptions are the settings shared by every file in a test run
+type testOptions struct {
+	params       paramSlice
+	includePaths []string
+	semantic     bool
+	update       bool
+}
+
+func testCommand(stdout io.Writer, stderr io.Writer) climax.Command {
+
+	return climax.Command{
+		Name:  "test",
+		Brief: "Parse each .scl file in a directory and compare the output to an .hcl file",
+		Usage: `[options] [file-glob|directory|directory/...]`,
+		Help: `Parse each .scl file in a directory and compare the output to an .hcl file. By default the output must
+match the .hcl file line by line, apart from blank lines; with --semantic, both are parsed and compared by
+structure, so the order of attributes, quoting, indentation and comments don't matter. With --update, the .hcl
+files that are missing or don't match are written with the current output instead.
+
+A file that should fail to parse has an .err file next to it instead of an .hcl file. The .err file holds text
+that the error must contain, or a regular expression between slashes that it must match, and optionally a line
+such as "line: 4" giving the line of the .scl file that the error must be reported at. Lines starting with # are
+ignored.
+
+A file can also test itself with @test blocks, which are run after it's compiled. A file whose @test blocks all
+pass doesn't need an .hcl file, and one isn't created for it by --update.
+
+Each argument can be a file, a glob, a directory or a pattern such as ./... that includes every directory below
+it. In directories, only the .scl files that have an .hcl or .err file or @test blocks are tested, and vendor
+directories are skipped unless --vendor is given. With -j, several files are tested at once; the results are
+still printed in order.
+
+With --report, the results are also written as JUnit XML, TAP or JSON, to stdout in place of the usual output
+or to the file given by --report-output.`,
+
+		Flags: append(standardParserParams(),
+			climax.Flag{
+				Name:  "semantic",
+				Short: "s",
+				Usage: `--semantic`,
+				Help:  `Compare the structure of the output and the .hcl file rather than their text`,
+			},
+			climax.Flag{
+				Name:  "update",
+				Short: "u",
+				Usage: `--update`,
+				Help:  `Write the output to each .hcl file that's missing or that differs, rather than failing`,
+			},
+			climax.Flag{
+				Name:  "vendor",
+				Usage: `--vendor`,
+				Help:  `Include vendor directories when searching directories for tests`,
+			},
+			climax.Flag{
+				Name:     "jobs",
+				Short:    "j",
+				Usage:    `--jobs 4`,
+				Help:     `The number of files to test at once (default 1)`,
+				Variable: true,
+			},
+			climax.Flag{
+				Name:     "report",
+				Short:    "r",
+				Usage:    `--report junit|tap|json`,
+				Help:     `Write a machine-readable report of the results`,
+				Variable: true,
+			},
+			climax.Flag{
+				Name:     "report-output",
+				Usage:    `--report-output /path/to/report.xml`,
+				Help:     `Write the report to a file, keeping the usual output on the console`,
+				Variable: true,
+			},
+		),
+
+		Handle: func(ctx climax.Context) int {
+
+			if len(ctx.Args) == 0 {
+				fmt.Fprintf(stderr, "At least one file glob is required. See `sep help test` for syntax")
+				return 1
+			}
+
+			var writeReport func(io.Writer, []testResult) error
+
+			if format, set := ctx.Get("report"); set {
+
+				if writeReport = testReportWriter(format); writeReport == nil {
+					fmt.Fprintf(stderr, "Error: Unknown report format %q. See `scl help test` for syntax\n", format)
+					return 1
+				}
+			}
+
+			reportPath, toFile := ctx.Get("report-output")
+
+			if toFile && writeReport == nil {
+				fmt.Fprintf(stderr, "Error: --report-output needs --report\n")
+				return 1
+			}
+
+			jobs := 1
+
+			if j, set := ctx.Get("jobs"); set {
+
+				var err error
+
+				if jobs, err = strconv.Atoi(j); err != nil || jobs < 1 {
+					fmt.Fprintf(stderr, "Error: Invalid number of jobs %q\n", j)
+					return 1
+				}
+			}
+
+			fileNames, err := testFileNames(ctx.Args, ctx.Is("vendor"))
+
+			if err != nil {
+				fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+				return 1
+			}
+
+			env, params, includePaths := parserParams(ctx)
+			params = append(env, params...)
+
+			options := testOptions{
+				params:       params,
+				includePaths: includePaths,
+				semantic:     ctx.Is("semantic"),
+				update:       ctx.Is("update"),
+			}
+
+			// A report on stdout replaces the usual output
+			console := writeReport == nil || toFile
+
+			results := testFiles(fileNames, options, jobs, func(result testResult) {
+				if console {
+					printTestResult(stdout, stderr, result)
+				}
+			})
+
+			if console {
+				printTestSummary(stdout, stderr, results, options.update)
+			}
+
+			if writeReport != nil {
+
+				if err := writeTestReport(stdout, reportPath, writeReport, results); err != nil {
+					fmt.Fprintf(stderr, "Error: Unable to write report: %s\n", err.Error())
+					return 1
+				}
+			}
+
+			return testExitCode(results)
+		},
+	}
+}
+
+func testExitCode(results []testResult) int {
+
+	for _, result := range results {
+		if result.status == testFailed {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// printTestResult prints the result of testing a file for people, with
+// failures on stderr
+func printTestResult(stdout, stderr io.Writer, result testResult) {
+
+	switch result.status {
+
+	case testPassed:
+
+		if result.message != "" {
+			fmt.Fprintf(stdout, "%-7s %s\t%.3fs [%s]\n", "ok", result.file, result.duration.Seconds(), result.message)
+		} else {
+			fmt.Fprintf(stdout, "%-7s %s\t%.3fs\n", "ok", result.file, result.duration.Seconds())
+		}
+
+	case testSkipped:
+		fmt.Fprintf(stdout, "%-7s %s [%s]\n", "?", result.file, result.message)
+
+	case testCreated, testUpdated:
+		fmt.Fprintf(stdout, "%-7s %s\n", result.status, hclFileName(result.file))
+
+	case testFailed:
+		fmt.Fprintf(stderr, "%-7s %s %s\n", "FAIL", result.file, result.message)
+
+		if len(result.details) > 0 {
+
+			fmt.Fprintln(stderr)
+
+			for _, d := range result.details {
+				fmt.Fprintf(stderr, "\t%s\n", d)
+			}
+
+			fmt.Fprintln(stderr)
+		}
+	}
+}
+
+func printTestSummary(stdout, stderr io.Writer, results []testResult, update bool) {
+
+	counts := map[string]int{}
+
+	for _, result := range results {
+		counts[result.status]++
+	}
+
+	if update {
+		fmt.Fprintf(stdout, "\nDone. %d file(s) created, %d file(s) updated.\n", counts[testCreated], counts[testUpdated])
+	}
+
+	if counts[testFailed] > 0 {
+		fmt.Fprintf(stderr, "\n[FAIL] %d error(s)\n", counts[testFailed])
+	}
+}
+
+// testFiles tests files with a number of jobs at once, calling done with
+// each result in the order of the files
+func testFiles(fileNames []string, options testOptions, jobs int, done func(testResult)) []testResult {
+
+	results := make([]testResult, len(fileNames))
+	finished := make([]chan struct{}, len(fileNames))
+	queue := make(chan int)
+
+	for i := range finished {
+		finished[i] = make(chan struct{})
+	}
+
+	for j := 0; j < jobs; j++ {
+		go func() {
+			for i := range queue {
+				results[i] = testFile(fileNames[i], options)
+				close(finished[i])
+			}
+		}()
+	}
+
+	go func() {
+		for i := range fileNames {
+			queue <- i
+		}
+
+		close(queue)
+	}()
+
+	for i := range fileNames {
+		<-finished[i]
+		done(results[i])
+	}
+
+	return results
+}
+
+/*
+testFileNames expands the arguments to the test command into file names. A
+file is tested whatever its name, and a glob is expanded in case the shell
+didn't do it. A directory includes the .scl files in it that have an .hcl or
+.err file, and a directory followed by /... includes those in every directory
+below it too, apart from vendor directories unless vendor is true.
+*/
+func testFileNames(args []string, vendor bool) (fileNames []string, err error) {
+
+	seen := map[string]bool{}
+
+	add := func(fileName string) {
+		if !seen[fileName] {
+			seen[fileName] = true
+			fileNames = append(fileNames, fileName)
+		}
+	}
+
+	for _, arg := range args {
+
+		if arg == "..." || strings.HasSuffix(arg, "/...") {
+
+			dir := strings.TrimSuffix(strings.TrimSuffix(arg, "..."), "/")
+
+			if dir == "" {
+				dir = "."
+			}
+
+			err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+
+				if err != nil {
+					return err
+				}
+
+				if info.IsDir() {
+
+					if !vendor && path != dir && info.Name() == "vendor" {
+						return filepath.SkipDir
+					}
+
+					return nil
+				}
+
+				if isTestFile(path) {
+					add(path)
+				}
+
+				return nil
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if info, err := os.Stat(arg); err == nil {
+
+			if !info.IsDir() {
+				add(arg)
+				continue
+			}
+
+			matches, err := filepath.Glob(filepath.Join(arg, "*.scl"))
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, path := range matches {
+				if isTestFile(path) {
+					add(path)
+				}
+			}
+
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid glob %s: %s", arg, err.Error())
+		}
+
+		// A missing file fails when it's tested, like any other file
+		if len(matches) == 0 && !strings.ContainsAny(arg, "*?[") {
+			add(arg)
+			continue
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("No files match %s", arg)
+		}
+
+		for _, path := range matches {
+			add(path)
+		}
+	}
+
+	return
+}
+
+// isTestFile is whether a file found in a directory is an .scl file with an
+// .hcl or .err file to compare with, or with @test blocks
+func isTestFile(path string) bool {
+
+	if filepath.Ext(path) != ".scl" {
+		return false
+	}
+
+	if content, err := ioutil.ReadFile(path); err == nil && testBlockMatcher.Match(content) {
+		return true
+	}
+
+	for _, ext := range []string{".hcl", ".err"} {
+		if info, err := os.Stat(strings.TrimSuffix(path, ".scl") + ext); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hclFileName(fileName string) string {
+	return strings.TrimSuffix(fileName, ".scl") + ".hcl"
+}
+
+// testFile parses an .scl file and compares the output with its .hcl file,
+// or its error with its .err file
+func testFile(fileName string, options testOptions) (result testResult) {
+
+	now := time.Now()
+
+	result = testResult{file: fileName, status: testPassed}
+
+	fail := func(message string, args ...interface{}) testResult {
+		result.status = testFailed
+		result.message = fmt.Sprintf(message, args...)
+		return result
+	}
+
+	defer func() {
+		result.duration = time.Since(now)
+	}()
+
+	fs := scl.NewDiskSystem()
+	parser, err := newParser(fs, options.params, options.includePaths)
+
+	if err != nil {
+		return fail("Unable to create new parser in CWD: %s", err.Error())
+	}
+
+	expected, err := readExpectedError(fs, fileName)
+
+	if err != nil {
+		return fail("Unable to read .err file: %s", err.Error())
+	}
+
+	err = parser.Parse(fileName)
+
+	if expected != nil {
+
+		if problem := expected.check(fileName, err); problem != "" {
+			return fail("%s", problem)
+		}
+
+		result.message = "expected error"
+		return
+	}
+
+	if err != nil {
+		return fail("Unable to parse file: %s", err.Error())
+	}
+
+	cases, err := runTestBlocks(fs, fileName, options)
+
+	if err != nil {
+		return fail("Unable to run @test blocks: %s", err.Error())
+	}
+
+	if failed := cases.failed(); len(failed) > 0 {
+
+		result = fail("%d of %d @test block(s) failed:", len(failed), len(cases))
+
+		for _, c := range failed {
+			result.details = append(result.details, strings.Split(c.String(), "\n")...)
+		}
+
+		return
+	}
+
+	if len(cases) > 0 {
+		result.message = fmt.Sprintf("%d @test block(s)", len(cases))
+	}
+
+	output := parser.String()
+	hclFilePath := hclFileName(fileName)
+	hclFile, _, err := fs.ReadCloser(hclFilePath)
+
+	if err != nil {
+
+		// The @test blocks are enough to test a file
+		if len(cases) > 0 {
+			return
+		}
+
+		if options.update {
+
+			if _, err := writeOutput(nil, hclFilePath, output+"\n"); err != nil {
+				return fail("Unable to write .hcl file: %s", err.Error())
+			}
+
+			result.status = testCreated
+			return
+		}
+
+		result.status = testSkipped
+		result.message = "no .hcl file"
+		return
+	}
+
+	hcl, err := ioutil.ReadAll(hclFile)
+	hclFile.Close()
+
+	if err != nil {
+		return fail("Unable to read .hcl file: %s", err.Error())
+	}
+
+	failure, differences, err := compareTestOutput(hcl, output, options.semantic)
+
+	// An .hcl file that can't be compared is replaced when updating
+	if options.update && (err != nil || len(differences) > 0) {
+
+		if _, err := writeOutput(nil, hclFilePath, output+"\n"); err != nil {
+			return fail("Unable to write .hcl file: %s", err.Error())
+		}
+
+		result.status = testUpdated
+		return
+	}
+
+	if err != nil {
+		return fail("Unable to compare: %s", err.Error())
+	}
+
+	if len(differences) > 0 {
+		result = fail("%s", failure)
+		result.details = differences
+	}
+
+	return
+}
+
+// testCases are the results of the @test blocks in a file
+type testCases scl.TestCases
+
+func (t testCases) failed() (failed testCases) {
+
+	for _, c := range t {
+		if !c.Passed() {
+			failed = append(failed, c)
+		}
+	}
+
+	return
+}
+
+// runTestBlocks runs the @test blocks in an .scl file
+func runTestBlocks(fs scl.FileSystem, fileName string, options testOptions) (testCases, error) {
+
+	runner, err := scl.NewTestRunner(fs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, includeDir := range options.includePaths {
+		runner.AddIncludePath(includeDir)
+	}
+
+	for _, p := range options.params {
+		runner.SetParam(p.name, p.value)
+	}
+
+	cases, err := runner.Run(fileName)
+
+	return testCases(cases), err
+}
+
+// compareTestOutput compares the output of a test with its .hcl file, and
+// returns the differences along with a description of the failure
+func compareTestOutput(hcl []byte, output string, semantic bool) (failure string, differences []string, err error) {
+
+	if semantic {
+		differences, err = scl.CompareHCL(hcl, []byte(output))
+		return "Semantic diff failed (expected != actual):", differences, err
+	}
+
+	newlineMatcher := regexp.MustCompile("\n\n")
+
+	hclLines := strings.Split(strings.TrimSuffix(newlineMatcher.ReplaceAllString(string(hcl), "\n"), "\n"), "\n")
+	sclLines := strings.Split(output, "\n")
+
+	diff := difflib.Diff(hclLines, sclLines)
+
+	for _, d := range diff {
+		if d.Delta != difflib.Common {
+
+			for _, d := range diff {
+				differences = append(differences, d.String())
+			}
+
+			break
+		}
+	}
+
+	return "Diff failed:", differences, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/homemade/scl"
+)
+
+type compileFunc func(cache *scl.Cache) (output string, parsers []scl.Parser, err error)
+
+// watch compiles, then polls every file the compilation read and recompiles
+// whenever one changes. Output and errors are only reprinted if they differ
+// from the previous compilation. It never returns.
+func watch(stdout, stderr io.Writer, interval time.Duration, outputPath string, compile compileFunc) int {
+
+	cache := scl.NewCache()
+	lastOutput, lastErr := "", ""
+
+	for {
+		output, parsers, err := compile(cache)
+
+		if err != nil {
+
+			// Any earlier successful output is kept, so that a mistake
+			// doesn't clobber a working configuration
+			if err.Error() != lastErr {
+				fmt.Fprintf(stderr, "[%s] Error: %s\n", time.Now().Format("15:04:05"), err.Error())
+			}
+
+			lastErr = err.Error()
+
+		} else {
+
+			if output != lastOutput || lastErr != "" {
+
+				changed, werr := writeOutput(stdout, outputPath, output)
+
+				if werr != nil {
+					fmt.Fprintf(stderr, "[%s] Error: Unable to write output: %s\n", time.Now().Format("15:04:05"), werr.Error())
+				} else if changed && outputPath != "" {
+					fmt.Fprintf(stderr, "[%s] Wrote %s\n", time.Now().Format("15:04:05"), outputPath)
+				}
+			}
+
+			lastOutput, lastErr = output, ""
+		}
+
+		for {
+			time.Sleep(interval)
+
+			// A failed compilation may have failed before reading every file
+			// it depends on, so it's always retried
+			if err != nil || stale(parsers) {
+				break
+			}
+		}
+	}
+}
+
+func stale(parsers []scl.Parser) bool {
+
+	for _, p := range parsers {
+		if s, err := p.Stale(); s || err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeOutput writes output to the named file if it differs from the file's
+// current content, or to stdout if there is no file name.
+func writeOutput(stdout io.Writer, outputPath, output string) (changed bool, err error) {
+
+	if outputPath == "" {
+		_, err = fmt.Fprint(stdout, output)
+		return true, err
+	}
+
+	if existing, err := ioutil.ReadFile(outputPath); err == nil && bytes.Equal(existing, []byte(output)) {
+		return false, nil
+	}
+
+	return true, ioutil.WriteFile(outputPath, []byte(output), 0644)
+}
@@ -0,0 +1,97 @@
+package scl
+
+import "strings"
+
+// CommentMode controls which SCL comments a Parser writes to its output
+type CommentMode int
+
+const (
+	// NoComments drops all // comments and /* */ docblocks, which is the
+	// default. HCL # comments are always written.
+	NoComments CommentMode = iota
+
+	// AllComments writes every // comment and /* */ docblock, including those
+	// inside mixins wherever the mixins are called
+	AllComments
+
+	// NoMixinComments writes comments, except for those inside mixin
+	// declarations. Comments in the body passed to a mixin are still written.
+	NoMixinComments
+)
+
+// pendingComment is a comment that's waiting for the next line of output, so
+// that comments documenting a mixin declaration can be dropped.
+type pendingComment struct {
+	branch  *scannerLine
+	content string
+}
+
+func (p *parser) SetCommentMode(mode CommentMode) {
+	p.commentMode = mode
+}
+
+func (p *parser) keepComment(scope *scope) bool {
+
+	switch p.commentMode {
+	case AllComments:
+		return true
+	case NoMixinComments:
+		return !scope.inMixin
+	}
+
+	return false
+}
+
+// lineComment formats a // comment for the output
+func lineComment(branch *scannerLine) string {
+	return string(branch.content)
+}
+
+// docblockComment formats a /* */ docblock for the output, indenting its
+// content by one level
+func (p *parser) docblockComment(branch *scannerLine) string {
+
+	lines := []string{}
+	p.parseBlockComment(branch.children, &lines, branch.line, 0)
+
+	comment := "/*\n"
+
+	for _, line := range lines {
+		if line != "" {
+			comment += p.indentation(1) + line
+		}
+		comment += "\n"
+	}
+
+	return comment + "*/"
+}
+
+func (p *parser) writeComments(comments *[]pendingComment) {
+
+	for _, comment := range *comments {
+		p.writeCommentToOutput(comment.branch, comment.content)
+	}
+
+	*comments = nil
+}
+
+// writeCommentToOutput writes a comment at the current indentation, adding it
+// to the syntax tree's comments as well.
+func (p *parser) writeCommentToOutput(branch *scannerLine, comment string) {
+
+	lines := strings.Split(comment, "\n")
+
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = p.indentedValue(line)
+		}
+	}
+
+	comment = strings.Join(lines, "\n")
+
+	if f, err := p.parseHCL(comment); err == nil {
+		p.addNodes(branch, f)
+	}
+
+	p.output = append(p.output, comment)
+}
@@ -0,0 +1,92 @@
+package scl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserCanPreserveComments(t *testing.T) {
+
+	for cycle, input := range []struct {
+		fileName string
+		mode     CommentMode
+		hcl      string
+	}{
+		{
+			fileName: "fixtures/valid/comments.scl",
+			mode:     AllComments,
+			hcl: `# This should be passed through
+// Ignored
+block {
+  // Ignored
+  value = 1
+  // Ignored
+}`,
+		},
+		{
+			fileName: "fixtures/valid/preserved-comments.scl",
+			mode:     AllComments,
+			hcl: `/*
+  Documents the block
+      nested
+*/
+block "a" {
+  // before the call
+  // inside mixin
+  value = 1
+  // in the body
+  other = 2
+  // trailing
+}
+// end`,
+		},
+		{
+			fileName: "fixtures/valid/preserved-comments.scl",
+			mode:     NoMixinComments,
+			hcl: `/*
+  Documents the block
+      nested
+*/
+block "a" {
+  // before the call
+  value = 1
+  // in the body
+  other = 2
+  // trailing
+}
+// end`,
+		},
+		{
+			fileName: "fixtures/valid/docblock.scl",
+			mode:     AllComments,
+			hcl:      ``,
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		p := newMockParser(t)
+		p.SetCommentMode(input.mode)
+
+		require.Nil(t, p.Parse(input.fileName))
+		require.Equal(t, input.hcl, p.String())
+	}
+}
+
+func Test_PreservedCommentsAreInTheSyntaxTree(t *testing.T) {
+
+	p := newMockParser(t)
+	p.SetCommentMode(NoMixinComments)
+
+	require.Nil(t, p.Parse("fixtures/valid/preserved-comments.scl"))
+
+	lines := []int{}
+
+	for _, group := range p.AST().Comments {
+		for _, comment := range group.List {
+			lines = append(lines, comment.Start.Line)
+		}
+	}
+
+	require.Equal(t, []int{12, 17, 19, 21, 22}, lines)
+}
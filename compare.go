@@ -0,0 +1,216 @@
+package scl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	hclparser "github.com/hashicorp/hcl/hcl/parser"
+)
+
+/*
+CompareHCL compares two pieces of HCL by their structure rather than their
+text, so that the order of attributes, quoting, indentation and comments make
+no difference. Each difference is reported as the path to a value and the
+two values, such as `service.web.port: 80 != 8080`, with the expected value
+first. Repeated blocks, and attributes that are assigned more than once, are
+compared in order, and are numbered in the path when either side has more
+than one.
+*/
+func CompareHCL(expected, actual []byte) ([]string, error) {
+
+	expectedValue, err := hclValue(expected)
+
+	if err != nil {
+		return nil, fmt.Errorf("Can't parse the expected HCL: %s", err)
+	}
+
+	actualValue, err := hclValue(actual)
+
+	if err != nil {
+		return nil, fmt.Errorf("Can't parse the actual HCL: %s", err)
+	}
+
+	differences := []string{}
+	compareValues("", expectedValue, actualValue, &differences)
+
+	return differences, nil
+}
+
+func hclValue(src []byte) (interface{}, error) {
+
+	f, err := hclparser.Parse(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := f.Node.(*ast.ObjectList)
+
+	if !ok {
+		return nil, fmt.Errorf("Unexpected root node %T", f.Node)
+	}
+
+	return compareObject(list)
+}
+
+// compareEntries holds everything under a key of an object, in order: its
+// values, and its blocks, which are nested once for each label. Keeping all of
+// them means that a key used for both a block and a value, or assigned twice,
+// is compared as it's written.
+type compareEntries []interface{}
+
+func compareObject(list *ast.ObjectList) (interface{}, error) {
+
+	out := make(map[string]interface{})
+
+	for _, item := range list.Items {
+
+		if len(item.Keys) == 0 {
+			return nil, fmt.Errorf("%s: Missing key", item.Pos())
+		}
+
+		value, err := nodeValue(item.Val, compareObject)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i := len(item.Keys) - 1; i > 0; i-- {
+			value = map[string]interface{}{keyValue(item.Keys[i]): compareEntries{value}}
+		}
+
+		key := keyValue(item.Keys[0])
+		entries, _ := out[key].(compareEntries)
+		out[key] = append(entries, value)
+	}
+
+	return out, nil
+}
+
+// missingValue stands for a value that only one side has
+type missingValue struct{}
+
+func compareValues(path string, expected, actual interface{}, differences *[]string) {
+
+	switch e := expected.(type) {
+
+	case map[string]interface{}:
+
+		if a, ok := actual.(map[string]interface{}); ok {
+
+			for _, key := range mergedKeys(e, a) {
+
+				ev, _ := e[key].(compareEntries)
+				av, _ := a[key].(compareEntries)
+
+				compareList(joinPath(path, key), ev, av, differences)
+			}
+
+			return
+		}
+
+	case []interface{}:
+
+		if a, ok := actual.([]interface{}); ok {
+			compareList(path, e, a, differences)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		*differences = append(*differences, fmt.Sprintf("%s: %s != %s", path, describeValue(expected), describeValue(actual)))
+	}
+}
+
+// compareList compares lists, or the entries of a key, element by element.
+// Elements are numbered in the path when either side has more than one.
+func compareList(path string, e, a []interface{}, differences *[]string) {
+
+	for i := 0; i < len(e) || i < len(a); i++ {
+
+		var ev, av interface{} = missingValue{}, missingValue{}
+
+		if i < len(e) {
+			ev = e[i]
+		}
+
+		if i < len(a) {
+			av = a[i]
+		}
+
+		elementPath := path
+
+		if len(e) > 1 || len(a) > 1 {
+			elementPath = fmt.Sprintf("%s[%d]", path, i)
+		}
+
+		compareValues(elementPath, ev, av, differences)
+	}
+}
+
+func mergedKeys(a, b map[string]interface{}) []string {
+
+	keys := []string{}
+
+	for key := range a {
+		keys = append(keys, key)
+	}
+
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func joinPath(path, key string) string {
+
+	if strings.ContainsAny(key, ". \t\"[]") {
+		key = fmt.Sprintf("%q", key)
+	}
+
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func describeValue(value interface{}) string {
+
+	switch v := value.(type) {
+	case missingValue:
+		return "(missing)"
+	case map[string]interface{}:
+		return "(block)"
+	case []interface{}:
+
+		if len(v) == 0 {
+			return "[]"
+		}
+
+		for _, element := range v {
+			if _, ok := element.(map[string]interface{}); !ok {
+				return fmt.Sprintf("(list of %d)", len(v))
+			}
+		}
+
+		if len(v) == 1 {
+			return "(block)"
+		}
+
+		return fmt.Sprintf("(%d blocks)", len(v))
+
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,95 @@
+package scl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HCLCanBeComparedByStructure(t *testing.T) {
+
+	for cycle, test := range []struct {
+		expected, actual string
+		differences      []string
+	}{
+		{
+			expected: "a = 1\nb = \"x\\n\"\nservice \"web\" {\n  port = 80\n}",
+			actual: `# A comment
+service "web" {
+      port = 80
+}
+b = <<EOT
+x
+EOT
+a = 1`,
+			differences: []string{},
+		},
+		{
+			expected:    `service "web" { port = 80 }`,
+			actual:      `service "web" { port = 8080 }`,
+			differences: []string{"service.web.port: 80 != 8080"},
+		},
+		{
+			expected: "a = 1\nb = \"1\"\nc = [1, 2]",
+			actual:   "b = 1\nc = [1]\nd = true",
+			differences: []string{
+				"a: 1 != (missing)",
+				`b: "1" != 1`,
+				"c[1]: 2 != (missing)",
+				"d: (missing) != true",
+			},
+		},
+		{
+			expected: "rule { name = \"a\" }\nrule { name = \"b\" }",
+			actual:   "rule { name = \"a\" }\nother = \"b\"",
+			differences: []string{
+				"other: (missing) != \"b\"",
+				"rule[1]: (block) != (missing)",
+			},
+		},
+		{
+			expected: "block \"a.b\" {\n  x = 1\n}\nblock \"c\" {}",
+			actual:   "block \"c\" {}\nblock \"a.b\" {\n  x = 2\n}",
+			differences: []string{
+				`block[0]."a.b": (block) != (missing)`,
+				"block[0].c: (missing) != (block)",
+				`block[1]."a.b": (missing) != (block)`,
+				"block[1].c: (block) != (missing)",
+			},
+		},
+		{
+			expected:    "wrapper {\n  inner = \"yes\"\n  inner \"no\" {}\n}",
+			actual:      "wrapper {\n  inner = \"yes\"\n  inner \"no\" {}\n}",
+			differences: []string{},
+		},
+		{
+			expected: "wrapper {\n  inner = \"yes\"\n  inner \"no\" {}\n}",
+			actual:   "wrapper {\n  inner \"no\" {}\n}",
+			differences: []string{
+				`wrapper.inner[0]: "yes" != (block)`,
+				"wrapper.inner[1]: (block) != (missing)",
+			},
+		},
+		{
+			expected: "a = 1",
+			actual:   "a = 2\na = 1",
+			differences: []string{
+				"a[0]: 1 != 2",
+				"a[1]: (missing) != 1",
+			},
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		differences, err := CompareHCL([]byte(test.expected), []byte(test.actual))
+
+		require.NoError(t, err)
+		require.Equal(t, test.differences, differences)
+	}
+}
+
+func Test_ComparingInvalidHCLIsAnError(t *testing.T) {
+
+	_, err := CompareHCL([]byte("a = \"x"), []byte("a = 1"))
+	require.Error(t, err)
+}
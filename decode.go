@@ -1,6 +1,10 @@
 package scl
 
-import "github.com/hashicorp/hcl"
+import (
+	"github.com/hashicorp/hcl"
+	hcl2 "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+)
 
 /*
 DecodeFile reads the given input file and decodes it into the structure given by `out`.
@@ -17,5 +21,44 @@ func DecodeFile(out interface{}, path string) error {
 		return err
 	}
 
-	return hcl.Decode(out, parser.String())
+	return decodeParser(out, parser)
+}
+
+/*
+DecodeFileHCL2 reads the given input file and decodes it into the structure
+given by `out` with gohcl, so it uses `hcl:"name,attr"` and
+`hcl:"name,block"` tags. The context can be nil. Any HCL2 diagnostics are
+returned as hcl.Diagnostics, with ranges in the SCL source. For partial
+decoding, use a `hcl:",remain"` field, or decode the result of the Parser's
+HCL2Body() directly.
+*/
+func DecodeFileHCL2(out interface{}, path string, ctx *hcl2.EvalContext) error {
+
+	parser, err := NewParser(NewDiskSystem())
+
+	if err != nil {
+		return err
+	}
+
+	if err := parser.Parse(path); err != nil {
+		return err
+	}
+
+	body, err := parser.HCL2Body()
+
+	if err != nil {
+		return err
+	}
+
+	if diags := gohcl.DecodeBody(body, ctx, out); diags.HasErrors() {
+		return diags
+	}
+
+	return nil
+}
+
+// decodeParser decodes the parser's syntax tree directly, rather than parsing
+// its output again.
+func decodeParser(out interface{}, parser Parser) error {
+	return hcl.DecodeObject(out, parser.AST())
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"testing"
 
+	hcl2 "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/stretchr/testify/require"
 )
 
@@ -48,3 +50,119 @@ func Test_AFileCanBeDecodedWithAnImplicitFileSystem(t *testing.T) {
 		}
 	}
 }
+
+func Test_AFileCanBeDecodedAsHCL2(t *testing.T) {
+
+	type service struct {
+		Name     string `hcl:"name,label"`
+		Function string `hcl:"function"`
+	}
+
+	type hook struct {
+		Name     string    `hcl:"name,label"`
+		Services []service `hcl:"service,block"`
+	}
+
+	type validation struct {
+		Name    string `hcl:"name,label"`
+		Message string `hcl:"message,optional"`
+	}
+
+	type field struct {
+		Name        string       `hcl:"name,label"`
+		Validations []validation `hcl:"validation,block"`
+		Hooks       []hook       `hcl:"hook,block"`
+	}
+
+	type model struct {
+		Name   string  `hcl:"name,label"`
+		Fields []field `hcl:"field,block"`
+		Hooks  []hook  `hcl:"hook,block"`
+	}
+
+	got := struct {
+		Models []model `hcl:"model,block"`
+	}{}
+
+	require.Nil(t, DecodeFileHCL2(&got, "fixtures/valid/sepia.scl", nil))
+
+	require.Equal(t, []model{
+		{
+			Name: "SomeModelIMadeUp",
+			Fields: []field{
+				{
+					Name: "someFieldInMyModel",
+					Validations: []validation{
+						{Name: "required", Message: "This field is required"},
+						{Name: "myValidationRule"},
+					},
+					Hooks: []hook{
+						{Name: "ui:after-validation", Services: []service{{"someLambdaInstance", "myFunction"}}},
+					},
+				},
+			},
+			Hooks: []hook{
+				{Name: "model:before-create", Services: []service{{"someLambdaInstance", "mySaveFunction"}}},
+			},
+		},
+	}, got.Models)
+}
+
+func Test_AnHCL2BodyCanBeDecodedPartially(t *testing.T) {
+
+	p, err := NewParser(NewDiskSystem())
+	require.Nil(t, err)
+	require.Nil(t, p.Parse("fixtures/valid/mixin-declaration.scl"))
+
+	body, err := p.HCL2Body()
+	require.Nil(t, err)
+
+	outer := struct {
+		Outer struct {
+			SomeArg string    `hcl:"someArg"`
+			Remain  hcl2.Body `hcl:",remain"`
+		} `hcl:"outer,block"`
+	}{}
+
+	require.False(t, gohcl.DecodeBody(body, nil, &outer).HasErrors())
+	require.Equal(t, "else", outer.Outer.SomeArg)
+
+	literals, diags := outer.Outer.Remain.Content(&hcl2.BodySchema{
+		Attributes: []hcl2.AttributeSchema{{Name: "someLiteral"}},
+	})
+
+	require.NotNil(t, literals)
+	require.Contains(t, literals.Attributes, "someLiteral")
+
+	// The remaining body's diagnostics are still in the SCL source
+	require.True(t, diags.HasErrors())
+	require.Equal(t, "fixtures/valid/mixin-declaration.scl", diags[0].Subject.Filename)
+}
+
+func Test_HCL2DiagnosticsAreInTheSCLSource(t *testing.T) {
+
+	got := struct {
+		Outer struct {
+			SomeLiteral     string `hcl:"someLiteral"`
+			MyCustomLiteral string `hcl:"myCustomLiteral"`
+			SomeArg         int    `hcl:"someArg"`
+			Wrapper         struct {
+				SomeOtherLiteral string `hcl:"someOtherLiteral"`
+				NestedLiteral    string `hcl:"nestedLiteral"`
+			} `hcl:"wrapper,block"`
+		} `hcl:"outer,block"`
+	}{}
+
+	err := DecodeFileHCL2(&got, "fixtures/valid/mixin-declaration.scl", nil)
+	require.NotNil(t, err)
+
+	diags, ok := err.(hcl2.Diagnostics)
+	require.True(t, ok)
+	require.Len(t, diags, 1)
+	require.Equal(t, "Unsuitable value type", diags[0].Summary)
+	require.Equal(t, &hcl2.Range{
+		Filename: "fixtures/valid/mixin-declaration.scl",
+		Start:    hcl2.Pos{Line: 10, Column: 9},
+		End:      hcl2.Pos{Line: 10, Column: 9},
+	}, diags[0].Subject)
+}
@@ -0,0 +1,52 @@
+package scl
+
+/*
+Dependency records a single file read by a Parser, and the files that it went
+on to include. Files passed directly to Parse() are the roots of the
+dependency graph, and have no Include, Reference or IncludePath.
+
+IncludePath is the include path or vendor directory that satisfied the
+include, and is empty if the include was resolved relative to the working
+directory. Vendored is true when IncludePath is the vendor directory next to
+the including file.
+*/
+type Dependency struct {
+	File        string
+	Include     string
+	Reference   string
+	IncludePath string
+	Vendored    bool
+	Children    Dependencies
+}
+
+/*
+Dependencies is a slice of Dependency, for convenience.
+*/
+type Dependencies []Dependency
+
+/*
+Files returns the name of every file in the dependency graph, in the order in
+which they were first read, with duplicates removed.
+*/
+func (d Dependencies) Files() (files []string) {
+
+	seen := make(map[string]bool)
+
+	var walk func(deps Dependencies)
+
+	walk = func(deps Dependencies) {
+		for _, dep := range deps {
+
+			if !seen[dep.File] {
+				seen[dep.File] = true
+				files = append(files, dep.File)
+			}
+
+			walk(dep.Children)
+		}
+	}
+
+	walk(d)
+
+	return
+}
@@ -0,0 +1,144 @@
+package scl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserRecordsTheIncludeGraph(t *testing.T) {
+
+	for cycle, test := range []struct {
+		fileName     string
+		includePaths []string
+		expected     Dependencies
+		files        []string
+		err          bool
+	}{
+		{
+			fileName: "fixtures/valid/basic.scl",
+			expected: Dependencies{
+				Dependency{File: "fixtures/valid/basic.scl"},
+			},
+			files: []string{"fixtures/valid/basic.scl"},
+		},
+		{
+			fileName: "fixtures/valid/import.scl",
+			expected: Dependencies{
+				Dependency{
+					File: "fixtures/valid/import.scl",
+					Children: Dependencies{
+						Dependency{
+							File:      "fixtures/valid/basic.scl",
+							Include:   "fixtures/valid/basic.scl",
+							Reference: "fixtures/valid/import.scl:1",
+						},
+						Dependency{
+							File:      "fixtures/valid/simple-mixin.scl",
+							Include:   "fixtures/valid/simple-mixin.scl",
+							Reference: "fixtures/valid/import.scl:1",
+						},
+					},
+				},
+			},
+			files: []string{
+				"fixtures/valid/import.scl",
+				"fixtures/valid/basic.scl",
+				"fixtures/valid/simple-mixin.scl",
+			},
+		},
+		{
+			fileName:     "fixtures/valid/vendor.scl",
+			includePaths: []string{"fixtures/valid"},
+			expected: Dependencies{
+				Dependency{
+					File: "fixtures/valid/vendor.scl",
+					Children: Dependencies{
+						Dependency{
+							File:        "fixtures/valid/vendor/vendored.scl",
+							Include:     "vendored.scl",
+							Reference:   "fixtures/valid/vendor.scl:3",
+							IncludePath: "fixtures/valid/vendor",
+							Vendored:    true,
+						},
+					},
+				},
+			},
+			files: []string{
+				"fixtures/valid/vendor.scl",
+				"fixtures/valid/vendor/vendored.scl",
+			},
+		},
+		{
+			fileName: "fixtures/invalid/error-in-include.scl",
+			expected: Dependencies{
+				Dependency{
+					File: "fixtures/invalid/error-in-include.scl",
+					Children: Dependencies{
+						Dependency{
+							File:      "fixtures/invalid/illegalToken.scl",
+							Include:   "fixtures/invalid/illegalToken.scl",
+							Reference: "fixtures/invalid/error-in-include.scl:1",
+						},
+					},
+				},
+			},
+			files: []string{
+				"fixtures/invalid/error-in-include.scl",
+				"fixtures/invalid/illegalToken.scl",
+			},
+			err: true,
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		p := newMockParser(t)
+
+		for _, ip := range test.includePaths {
+			p.AddIncludePath(ip)
+		}
+
+		err := p.Parse(test.fileName)
+		require.Equal(t, test.err, err != nil)
+
+		require.Equal(t, test.expected, p.Dependencies())
+		require.Equal(t, test.files, p.Dependencies().Files())
+	}
+}
+
+func Test_AParserRecordsTheIncludePathThatSatisfiedAnInclude(t *testing.T) {
+
+	then := time.Date(2016, 11, 8, 15, 0, 0, 0, time.UTC)
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "include(\"shared\")\ninclude(\"shared\")", then)
+	fs.set("lib0/other.scl", "", then)
+	fs.set("lib1/shared.scl", "value = 1", then)
+
+	p, err := NewParser(fs)
+	require.Nil(t, err)
+
+	p.AddIncludePath("lib0")
+	p.AddIncludePath("lib1")
+
+	require.Nil(t, p.Parse("main.scl"))
+
+	shared := func(line string) Dependency {
+		return Dependency{
+			File:        "lib1/shared.scl",
+			Include:     "shared.scl",
+			Reference:   "main.scl:" + line,
+			IncludePath: "lib1",
+		}
+	}
+
+	require.Equal(t, Dependencies{
+		Dependency{
+			File:     "main.scl",
+			Children: Dependencies{shared("1"), shared("2")},
+		},
+	}, p.Dependencies())
+
+	require.Equal(t, []string{"main.scl", "lib1/shared.scl"}, p.Dependencies().Files())
+}
@@ -0,0 +1,108 @@
+package scl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Directives are built-in statements that start with an @, like a mixin
+// declaration, but are followed by their arguments rather than a signature
+const (
+	directiveAssert = "assert"
+	directiveDebug  = "debug"
+	directiveError  = "error"
+	directiveParam  = "param"
+	directiveTest   = "test"
+	directiveWarn   = "warn"
+)
+
+// A Warning is a message from an @warn directive, at the line of the @warn
+type Warning struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s:%d: %s", w.File, w.Line, w.Message)
+}
+
+// Warnings is a list of warnings, in the order they were made
+type Warnings []Warning
+
+func (p *parser) Warnings() Warnings {
+	return p.warnings
+}
+
+func (p *parser) SetDebugWriter(w io.Writer) {
+	p.debug = w
+}
+
+func (p *parser) parseDirective(branch *scannerLine, tkn *tokeniser, tokens []token, scope *scope) error {
+
+	arguments := ""
+
+	if len(tokens) > 1 {
+		arguments = tokens[1].content
+	}
+
+	switch tokens[0].content {
+
+	case directiveTest:
+		return p.parseTestDirective(branch, arguments, scope)
+
+	case directiveAssert:
+		return p.parseAssertDirective(branch, tkn, arguments, scope)
+
+	case directiveParam:
+		return p.parseParamDirective(branch, tokens, scope)
+
+	case directiveError, directiveWarn, directiveDebug:
+		return p.parseMessageDirective(branch, tokens[0].content, arguments, scope)
+	}
+
+	return p.err(branch, "Unknown directive @%s", tokens[0].content)
+}
+
+// parseMessageDirective handles @error, which stops the compilation, @warn,
+// which records a warning, and @debug, which writes a value to the debug
+// writer
+func (p *parser) parseMessageDirective(branch *scannerLine, directive, arguments string, scope *scope) error {
+
+	if arguments == "" {
+		return p.err(branch, "@%s needs a message", directive)
+	}
+
+	message, err := scope.interpolateLiteral(arguments)
+
+	if err != nil {
+		return p.err(branch, err.Error())
+	}
+
+	switch directive {
+
+	case directiveError:
+		return p.err(branch, "%s", unquote(message))
+
+	case directiveWarn:
+		p.warnings = append(p.warnings, Warning{File: branch.file, Line: branch.line, Message: unquote(message)})
+
+	case directiveDebug:
+		if p.debug != nil {
+			fmt.Fprintf(p.debug, "%s DEBUG: %s\n", branch.String(), message)
+		}
+	}
+
+	return nil
+}
+
+// unquote removes the quotes around a string, if it has them
+func unquote(s string) string {
+
+	if l := len(s); l > 1 && s[0] == s[l-1] && strings.ContainsRune(`"'`, rune(s[0])) {
+		return s[1 : l-1]
+	}
+
+	return s
+}
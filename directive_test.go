@@ -0,0 +1,82 @@
+package scl
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserCanReportMessagesFromDirectives(t *testing.T) {
+
+	for cycle, test := range []struct {
+		source   string
+		err      string
+		warnings Warnings
+		debug    string
+		hcl      string
+	}{
+		{
+			source: `$env = "staging"
+
+@environment($name)
+    @error "Unknown environment $name"
+
+block
+    environment($env)`,
+			err: `[main.scl:4] Unknown environment "staging"`,
+		},
+		{
+			source: `$port = 80
+
+@warn "Port $port is deprecated"
+@warn 'Another'
+a = $port`,
+			warnings: Warnings{
+				{File: "main.scl", Line: 3, Message: "Port 80 is deprecated"},
+				{File: "main.scl", Line: 4, Message: "Another"},
+			},
+			hcl: "a = 80",
+		},
+		{
+			source: `$name = "web"
+
+@debug $name
+@debug "name is ${name}!"`,
+			debug: "main.scl:3 DEBUG: \"web\"\nmain.scl:4 DEBUG: \"name is \"web\"!\"\n",
+		},
+		{
+			source: `@warn`,
+			err:    "[main.scl:1] @warn needs a message",
+		},
+		{
+			source: `@error "$missing"`,
+			err:    "[main.scl:1] Unknown variable '$missing'",
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		fs := newMemoryFileSystem()
+		fs.set("main.scl", test.source, time.Now())
+
+		p, err := NewParser(fs)
+		require.NoError(t, err)
+
+		debug := &bytes.Buffer{}
+		p.SetDebugWriter(debug)
+
+		err = p.Parse("main.scl")
+
+		if test.err != "" {
+			require.Error(t, err)
+			require.Equal(t, test.err, err.Error())
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, test.warnings, p.Warnings())
+		require.Equal(t, test.debug, debug.String())
+		require.Equal(t, test.hcl, p.String())
+	}
+}
@@ -28,9 +28,26 @@ type MixinDoc struct {
 	Reference string
 	Signature string
 	Docs      string
+	Params    MixinParams
 	Children  MixinDocs
 }
 
+/*
+MixinParam describes a parameter of a mixin. Type is the type written in the
+signature, such as number or "dev"|"prod", and is empty if there isn't one.
+Default is the default value as written, and a parameter without one is
+required.
+*/
+type MixinParam struct {
+	Name     string
+	Type     string
+	Default  string
+	Required bool
+}
+
+// MixinParams is a slice of MixinParams, in the order of the signature
+type MixinParams []MixinParam
+
 /*
 MixinDocs is a slice of MixinDocs, for convenience.
 */
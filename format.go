@@ -0,0 +1,185 @@
+package scl
+
+import (
+	"regexp"
+	"strings"
+)
+
+var attributeLineMatcher = regexp.MustCompile(`^([ \t]*)("(?:[^"\\]|\\.)*"|[^\s="]+)\s*=\s*(.*)$`)
+
+/*
+OutputFormat controls how a Parser lays out its HCL output. The zero value
+gives the default layout, which indents with two spaces and adds nothing.
+*/
+type OutputFormat struct {
+	// IndentWidth is the number of spaces for each level of indentation. If
+	// it's zero, two spaces are used.
+	IndentWidth int
+
+	// Tabs indents with a tab for each level instead of spaces
+	Tabs bool
+
+	// BlankLines separates top-level blocks from whatever is around them with
+	// a blank line. Comments stay with the block that follows them.
+	BlankLines bool
+
+	// AlignEquals lines up the equals signs of consecutive single-line
+	// attributes at the same level, as hclfmt does
+	AlignEquals bool
+
+	// Header is written as a // comment at the top of the output, such as a
+	// note that the file is generated
+	Header string
+}
+
+func (p *parser) SetOutputFormat(format OutputFormat) {
+	p.format = format
+}
+
+// indentation returns the indentation for a number of levels
+func (p *parser) indentation(levels int) string {
+
+	if p.format.Tabs {
+		return strings.Repeat("\t", levels)
+	}
+
+	width := p.format.IndentWidth
+
+	if width <= 0 {
+		width = hclIndentSize
+	}
+
+	return strings.Repeat(" ", levels*width)
+}
+
+// formatOutput applies the layout options that need to see the whole of the
+// output
+func (p *parser) formatOutput() []string {
+
+	lines := p.output
+
+	if p.format.AlignEquals {
+		lines = alignEquals(lines)
+	}
+
+	if p.format.BlankLines {
+		lines = separateBlocks(lines)
+	}
+
+	if p.format.Header != "" {
+
+		header := []string{}
+
+		for _, line := range strings.Split(strings.TrimRight(p.format.Header, "\n"), "\n") {
+			header = append(header, strings.TrimRight("// "+line, " "))
+		}
+
+		lines = append(append(header, ""), lines...)
+	}
+
+	return lines
+}
+
+func alignEquals(lines []string) []string {
+
+	out := make([]string, len(lines))
+	copy(out, lines)
+
+	// Each group is a run of single-line attributes with the same indentation
+	start := 0
+	indentation := ""
+
+	align := func(end int) {
+
+		if end-start < 2 {
+			return
+		}
+
+		width := 0
+
+		for _, line := range out[start:end] {
+			if key := attributeLineMatcher.FindStringSubmatch(line)[2]; len(key) > width {
+				width = len(key)
+			}
+		}
+
+		for i := start; i < end; i++ {
+			matches := attributeLineMatcher.FindStringSubmatch(out[i])
+			out[i] = matches[1] + matches[2] + strings.Repeat(" ", width-len(matches[2])) + " = " + matches[3]
+		}
+	}
+
+	for i, line := range out {
+
+		matches := attributeLineMatcher.FindStringSubmatch(line)
+		trimmed := strings.TrimSpace(line)
+
+		// Commented-out attributes aren't aligned
+		comment := strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*")
+
+		if matches == nil || comment || strings.Contains(line, "\n") || strings.HasSuffix(line, "{") {
+			align(i)
+			start = i + 1
+			continue
+		}
+
+		if matches[1] != indentation {
+			align(i)
+			start = i
+			indentation = matches[1]
+		}
+	}
+
+	align(len(out))
+
+	return out
+}
+
+func separateBlocks(lines []string) []string {
+
+	topLevel := func(line string) bool {
+		return line != "" && line[0] != ' ' && line[0] != '\t'
+	}
+
+	comment := func(line string) bool {
+		return topLevel(line) && (strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "/*"))
+	}
+
+	var (
+		out           []string
+		previousBlock bool
+		itemStart     int
+	)
+
+	for i := 0; i <= len(lines); i++ {
+
+		// Items start at a top-level line other than the end of a block, or at
+		// the comments before it
+		if i < len(lines) && (!topLevel(lines[i]) || lines[i][0] == '}' || i > 0 && comment(lines[i-1])) {
+			continue
+		}
+
+		if i > itemStart {
+
+			item := lines[itemStart:i]
+			block := false
+
+			for _, line := range item {
+				if topLevel(line) && (strings.HasSuffix(line, "{") || strings.HasSuffix(line, "{}")) {
+					block = true
+				}
+			}
+
+			if len(out) > 0 && (block || previousBlock) {
+				out = append(out, "")
+			}
+
+			out = append(out, item...)
+			previousBlock = block
+		}
+
+		itemStart = i
+	}
+
+	return out
+}
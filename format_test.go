@@ -0,0 +1,118 @@
+package scl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserCanFormatItsOutput(t *testing.T) {
+
+	for cycle, input := range []struct {
+		fileName string
+		format   OutputFormat
+		hcl      string
+	}{
+		{
+			fileName: "fixtures/valid/basic.scl",
+			format:   OutputFormat{IndentWidth: 4},
+			hcl: `wrapper {
+    inner = "yes"
+    another {
+        yet_another = "123"
+    }
+    inner "no"{}
+}`,
+		},
+		{
+			fileName: "fixtures/valid/basic.scl",
+			format:   OutputFormat{Tabs: true},
+			hcl:      "wrapper {\n\tinner = \"yes\"\n\tanother {\n\t\tyet_another = \"123\"\n\t}\n\tinner \"no\"{}\n}",
+		},
+		{
+			fileName: "fixtures/valid/mixin-declaration.scl",
+			format:   OutputFormat{AlignEquals: true},
+			hcl: `outer {
+  someLiteral = "hello"
+  wrapper {
+    someOtherLiteral = "world"
+    nestedLiteral    = "world"
+  }
+  myCustomLiteral = "something"
+  someArg         = "else"
+}`,
+		},
+		{
+			fileName: "fixtures/valid/repeated-blocks.scl",
+			format:   OutputFormat{BlankLines: true, Header: "Generated by scl"},
+			hcl: `// Generated by scl
+
+service "web" {
+  port = 80
+  listener {
+    protocol = "http"
+  }
+  listener {
+    protocol = "https"
+  }
+}
+
+service "api" {
+  port = 8080
+}
+
+service "web" {
+  port = 81
+  tags = ["a", "b"]
+}`,
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		p := newMockParser(t)
+		p.SetOutputFormat(input.format)
+
+		require.Nil(t, p.Parse(input.fileName))
+		require.Equal(t, input.hcl, p.String())
+	}
+}
+
+func Test_BlankLinesKeepCommentsWithTheirBlocks(t *testing.T) {
+
+	p := newMockParser(t)
+	p.SetCommentMode(AllComments)
+	p.SetOutputFormat(OutputFormat{BlankLines: true})
+
+	require.Nil(t, p.Parse("fixtures/valid/variable-assignment.scl"))
+	require.Nil(t, p.Parse("fixtures/valid/preserved-comments.scl"))
+
+	require.Contains(t, p.String(), `}
+
+origin = "parent value"
+// Conditional assignments
+t1 = "http://localhost"
+t2 = "http://localhost"
+
+/*
+  Documents the block
+      nested
+*/
+block "a" {`)
+}
+
+func Test_AlignEqualsSkipsComments(t *testing.T) {
+
+	require.Equal(t, []string{
+		"a    = 1",
+		"long = 2",
+		"#key = v",
+		"// x = y",
+		"b = 3",
+	}, alignEquals([]string{
+		"a = 1",
+		"long = 2",
+		"#key = v",
+		"// x = y",
+		"b = 3",
+	}))
+}
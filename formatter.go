@@ -0,0 +1,340 @@
+package scl
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// formatIndent is the indentation for each level of formatted SCL
+const formatIndent = "    "
+
+var literalAssignmentMatcher = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|[^\s="#]+)\s*=\s*(.*)$`)
+
+// formattedLine is a line of formatted SCL, kept apart from any comment at the
+// end of it so that comments can be aligned
+type formattedLine struct {
+	indent  string
+	code    string
+	comment string
+}
+
+type formatter struct {
+	source []string
+	lines  []formattedLine
+}
+
+/*
+Format returns SCL source in its canonical form. Each level is indented with
+four spaces, and there's exactly one space around `=`, `:=` and `?=`, after the
+commas in mixin signatures and argument lists, and before comments at the end
+of a line; the comments at the ends of consecutive lines are aligned. Runs of
+blank lines are reduced to one, and blank lines at the start of a block or
+the file are removed. Braces at the ends of lines, which SCL ignores, are
+removed unless they're an empty `{}`. The content of heredocs and docblocks is
+kept byte-for-byte, apart from the indentation of docblocks.
+
+Because indentation is significant, the formatted source has the structure
+that the SCL scanner sees in the original, even where the original mixes tabs
+and spaces.
+*/
+func Format(src []byte) ([]byte, error) {
+
+	lines, err := newScanner(bytes.NewReader(src), "<input>").scan()
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &formatter{
+		source: strings.Split(strings.Replace(string(src), "\r\n", "\n", -1), "\n"),
+	}
+
+	if err := f.format(lines, 0, 0); err != nil {
+		return nil, err
+	}
+
+	return f.bytes(), nil
+}
+
+// format writes a tree of lines at the given level. previous is the source
+// line before the tree, which tells whether it starts after a blank line.
+func (f *formatter) format(tree scannerTree, level, previous int) error {
+
+	tkn := newTokeniser()
+
+	for i, branch := range tree {
+
+		if i > 0 && f.blankLineBefore(previous, branch.line) {
+			f.lines = append(f.lines, formattedLine{})
+		}
+
+		indent := strings.Repeat(formatIndent, level)
+		content := strings.TrimSpace(f.sourceLine(branch.line))
+
+		// Heredocs are kept as they are after their first line
+		if strings.Contains(string(branch.content), "\n") {
+
+			end := branch.line + strings.Count(string(branch.content), "\n") - 1
+			heredoc := append([]string{f.formatCode(tkn, branch, content)}, f.source[branch.line:end]...)
+
+			f.lines = append(f.lines, formattedLine{indent: indent, code: strings.Join(heredoc, "\n")})
+			previous = end
+			continue
+		}
+
+		code := tkn.stripComments(&scannerLine{content: lineContent(content)})
+		comment := strings.TrimSpace(content[len(code):])
+
+		switch {
+		case code == "":
+			f.lines = append(f.lines, formattedLine{indent: indent, code: strings.TrimRight(content, " \t")})
+
+		case docblockStartMatcher.MatchString(code):
+			f.lines = append(f.lines, formattedLine{indent: indent, code: code})
+			previous = f.docblock(branch, indent+formatIndent)
+			continue
+
+		default:
+			f.lines = append(f.lines, formattedLine{indent: indent, code: f.formatCode(tkn, branch, code), comment: comment})
+		}
+
+		previous = branch.line
+
+		if len(branch.children) > 0 {
+
+			if err := f.format(branch.children, level+1, previous); err != nil {
+				return err
+			}
+
+			previous = lastLine(branch)
+		}
+	}
+
+	return nil
+}
+
+func (f *formatter) sourceLine(line int) string {
+
+	if line < 1 || line > len(f.source) {
+		return ""
+	}
+
+	return f.source[line-1]
+}
+
+// blankLineBefore reports whether there's a blank line in the source between
+// two lines, as opposed to only lines such as closing braces
+func (f *formatter) blankLineBefore(previous, line int) bool {
+
+	for i := previous + 1; i < line; i++ {
+		if strings.TrimSpace(f.sourceLine(i)) == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// docblock writes the content of a docblock, keeping the relative indentation
+// of its lines, and returns its last line
+func (f *formatter) docblock(branch *scannerLine, indent string) int {
+
+	end := lastLine(branch)
+	content := f.source[branch.line:end]
+
+	// The least indented line sets the indentation of the content
+	common := ""
+	found := false
+
+	for _, line := range content {
+
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+		if !found || len(lineIndent) < len(common) {
+			common = lineIndent
+			found = true
+		}
+	}
+
+	for _, line := range content {
+
+		line = strings.TrimRight(line, " \t")
+
+		if line == "" {
+			f.lines = append(f.lines, formattedLine{})
+			continue
+		}
+
+		f.lines = append(f.lines, formattedLine{indent: indent, code: strings.TrimPrefix(line, common)})
+	}
+
+	return end
+}
+
+func lastLine(branch *scannerLine) int {
+
+	if len(branch.children) == 0 {
+		return branch.line
+	}
+
+	return lastLine(branch.children[len(branch.children)-1])
+}
+
+// formatCode normalises the spacing of a line, without its comment
+func (f *formatter) formatCode(tkn *tokeniser, branch *scannerLine, code string) string {
+
+	// Braces at the ends of lines are ignored, but empty objects are kept
+	if !strings.HasSuffix(code, "{}") {
+		code = strings.TrimRight(code, " \t{}")
+	}
+
+	tokens, err := tkn.tokenise(&scannerLine{file: branch.file, line: branch.line, content: lineContent(code)})
+
+	if err != nil || len(tokens) == 0 {
+		return code
+	}
+
+	switch tokens[0].kind {
+
+	case tokenMixinDeclaration:
+		return "@" + tokens[0].content + "(" + formatArguments(tokens[1:]) + ")"
+
+	case tokenDirective:
+
+		if len(tokens) > 1 {
+			return "@" + tokens[0].content + " " + tokens[1].content
+		}
+
+		return "@" + tokens[0].content
+
+	case tokenFunctionCall:
+
+		if shortFunctionMatcher.MatchString(code) {
+			return code
+		}
+
+		call := tokens[0].content + "(" + formatArguments(tokens[1:]) + ")"
+
+		if strings.HasSuffix(code, ":") {
+			call += ":"
+		}
+
+		return call
+
+	case tokenVariableAssignment:
+		return fmt.Sprintf("$%s = %s", tokens[0].content, tokens[1].content)
+
+	case tokenVariableDeclaration:
+		return fmt.Sprintf("$%s := %s", tokens[0].content, tokens[1].content)
+
+	case tokenConditionalVariableAssignment:
+		return fmt.Sprintf("$%s ?= %s", tokens[0].content, tokens[1].content)
+
+	case tokenLiteral:
+		if matches := literalAssignmentMatcher.FindStringSubmatch(code); matches != nil {
+			return matches[1] + " = " + matches[2]
+		}
+	}
+
+	return code
+}
+
+func formatArguments(tokens []token) string {
+
+	arguments := []string{}
+
+	// Types follow the argument and its default, but are written before the
+	// default
+	typeAt := func(i int) string {
+
+		if i < len(tokens) && tokens[i].kind == tokenParamType {
+			return ": " + tokens[i].content
+		}
+
+		return ""
+	}
+
+	for i := 0; i < len(tokens); i++ {
+
+		switch tokens[i].kind {
+
+		case tokenVariable:
+
+			argument := "$" + tokens[i].content + typeAt(i+1)
+
+			if typeAt(i+1) != "" {
+				i++
+			}
+
+			arguments = append(arguments, argument)
+
+		case tokenVariableAssignment:
+
+			argument := fmt.Sprintf("$%s%s = %s", tokens[i].content, typeAt(i+2), tokens[i+1].content)
+
+			if typeAt(i+2) != "" {
+				i++
+			}
+
+			arguments = append(arguments, argument)
+			i++
+
+		default:
+			arguments = append(arguments, tokens[i].content)
+		}
+	}
+
+	return strings.Join(arguments, ", ")
+}
+
+// bytes writes the formatted lines, aligning the comments at the ends of
+// consecutive lines with the same indentation
+func (f *formatter) bytes() []byte {
+
+	var buf bytes.Buffer
+
+	for start := 0; start < len(f.lines); {
+
+		end := start + 1
+
+		if f.lines[start].comment != "" {
+			for end < len(f.lines) && f.lines[end].comment != "" && f.lines[end].indent == f.lines[start].indent {
+				end++
+			}
+		}
+
+		width := 0
+
+		for _, line := range f.lines[start:end] {
+			if len(line.code) > width {
+				width = len(line.code)
+			}
+		}
+
+		for _, line := range f.lines[start:end] {
+
+			if line.code == "" {
+				buf.WriteString("\n")
+				continue
+			}
+
+			buf.WriteString(line.indent + line.code)
+
+			if line.comment != "" {
+				buf.WriteString(strings.Repeat(" ", width-len(line.code)+1) + line.comment)
+			}
+
+			buf.WriteString("\n")
+		}
+
+		start = end
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,123 @@
+package scl
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FormattingNormalisesSCL(t *testing.T) {
+
+	for cycle, input := range []struct {
+		src       string
+		formatted string
+	}{
+		{
+			src: "\n\n@m( $x,$y=\"a b\" , $z )   // sig\n  value=$x // one\n  longer_name   =   $y   // two\n\n\n  __body__()\n",
+			formatted: `@m($x, $y = "a b", $z) // sig
+    value = $x       // one
+    longer_name = $y // two
+
+    __body__()
+`,
+		},
+		{
+			src: "block {\n\t$v:=\"q\"\n\t$w ?=   \"r\"\n\tm(1,\"2\",   $v) {\n\t\tother=2\n\t}\n}\nx = {}\n",
+			formatted: `block
+    $v := "q"
+    $w ?= "r"
+    m(1, "2", $v)
+        other = 2
+x = {}
+`,
+		},
+		{
+			src: "@typed($port:number,$env :\"dev\"|\"prod\"=\"dev\",  $on = true)\n  @test   \"a test\"\n",
+			formatted: `@typed($port: number, $env: "dev"|"prod" = "dev", $on = true)
+    @test "a test"
+`,
+		},
+		{
+			src:       "/*\n      docs\n        more\n\n      end\n*/\nfoo = <<EOF\n  raw  text = 1  \nEOF\n",
+			formatted: "/*\n    docs\n      more\n\n    end\n*/\nfoo = <<EOF\n  raw  text = 1  \nEOF\n",
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		formatted, err := Format([]byte(input.src))
+
+		require.Nil(t, err)
+		require.Equal(t, input.formatted, string(formatted))
+	}
+}
+
+func Test_FormattingPreservesSemantics(t *testing.T) {
+
+	fileNames, err := filepath.Glob("fixtures/valid/*.scl")
+	require.Nil(t, err)
+
+	fileNames = append(fileNames, "fixtures/valid/vendor/vendored.scl")
+
+	original := newMemoryFileSystem()
+	formatted := newMemoryFileSystem()
+
+	for _, fileName := range fileNames {
+
+		src, err := ioutil.ReadFile(fileName)
+		require.Nil(t, err)
+
+		out, err := Format(src)
+		require.Nil(t, err)
+
+		// Formatting is idempotent
+		again, err := Format(out)
+		require.Nil(t, err)
+		require.Equal(t, string(out), string(again), fileName)
+
+		original.set(fileName, string(src), time.Time{})
+		formatted.set(fileName, string(out), time.Time{})
+	}
+
+	for _, fileName := range fileNames {
+		t.Logf("File %s", fileName)
+
+		p0, err := NewParser(original)
+		require.Nil(t, err)
+
+		p1, err := NewParser(formatted)
+		require.Nil(t, err)
+
+		err0 := p0.Parse(fileName)
+		err1 := p1.Parse(fileName)
+
+		require.Equal(t, err0, err1)
+		require.Equal(t, p0.String(), p1.String())
+
+		docs0, err0 := p0.Documentation(fileName)
+		docs1, err1 := p1.Documentation(fileName)
+
+		require.Equal(t, err0, err1)
+		require.Equal(t, documentedNames(docs0), documentedNames(docs1))
+	}
+}
+
+// documentedNames flattens mixin documentation to the parts that formatting
+// mustn't change, since signatures and line numbers can
+func documentedNames(docs MixinDocs) (names []string) {
+
+	for _, doc := range docs {
+		names = append(names, doc.Name+": "+doc.Docs)
+		names = append(names, documentedNames(doc.Children)...)
+	}
+
+	return
+}
+
+func Test_FormattingReportsUnterminatedHeredocs(t *testing.T) {
+
+	_, err := Format([]byte("foo = <<EOF\nbar\n"))
+	require.NotNil(t, err)
+}
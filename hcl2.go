@@ -0,0 +1,331 @@
+package scl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	hcltoken "github.com/hashicorp/hcl/hcl/token"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// hcl2FileName is the name given to HCL2 output when it's parsed
+const hcl2FileName = "<hcl2>"
+
+/*
+hcl2Writer writes the syntax tree as HCL2 native syntax. The differences from
+HCL are:
+
+  - blocks are only written as blocks if they were written without an equals
+    sign; `name = { ... }` becomes an attribute with an object value, and any
+    blocks inside an object value become nested objects;
+  - block labels are always quoted;
+  - attributes can only be defined once in each block;
+  - `%{` in strings and heredocs is escaped, since it starts a template
+    directive in HCL2, while `${` interpolations are kept as they are;
+  - comments are not written.
+
+The SCL source position of every line written is recorded, so that errors
+from the HCL2 parser can be reported against the SCL.
+*/
+type hcl2Writer struct {
+	buf       bytes.Buffer
+	positions hcl2Positions
+	indent    int
+}
+
+func (p *parser) HCL2(validate bool) ([]byte, error) {
+
+	src, positions, err := p.hcl2()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if validate {
+		if _, err := parseHCL2(src, positions); err != nil {
+			return nil, err
+		}
+	}
+
+	return src, nil
+}
+
+func (p *parser) HCL2Body() (hcl.Body, error) {
+
+	src, positions, err := p.hcl2()
+
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := parseHCL2(src, positions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &hcl2Body{f.Body, positions}, nil
+}
+
+func (p *parser) hcl2() ([]byte, hcl2Positions, error) {
+
+	list, err := p.objectList()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := &hcl2Writer{}
+
+	if err := w.body(list); err != nil {
+		return nil, nil, err
+	}
+
+	return w.buf.Bytes(), w.positions, nil
+}
+
+// parseHCL2 parses HCL2 output, reporting any errors at the SCL source
+// position that produced them.
+func parseHCL2(src []byte, positions hcl2Positions) (*hcl.File, error) {
+
+	f, diags := hclsyntax.ParseConfig(src, hcl2FileName, hcl.Pos{Line: 1, Column: 1})
+
+	if diags.HasErrors() {
+		return nil, hcl2Error(diags, positions)
+	}
+
+	return f, nil
+}
+
+func hcl2Error(diags hcl.Diagnostics, positions hcl2Positions) error {
+
+	for _, diag := range diags {
+
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+
+		message := diag.Summary
+
+		if diag.Detail != "" {
+			message += ": " + diag.Detail
+		}
+
+		if diag.Subject != nil {
+			if pos, ok := positions.lookup(diag.Subject.Filename, diag.Subject.Start.Line); ok {
+				return fmt.Errorf("%s:%d: %s", pos.Filename, pos.Line, message)
+			}
+		}
+
+		return fmt.Errorf("%s", message)
+	}
+
+	return diags
+}
+
+func (w *hcl2Writer) line(pos hcltoken.Pos, format string, args ...interface{}) {
+
+	text := indentLines(fmt.Sprintf(format, args...), w.indent)
+
+	w.buf.WriteString(text)
+	w.buf.WriteString("\n")
+
+	for i := 0; i <= strings.Count(text, "\n"); i++ {
+		pos := pos
+		pos.Line += i
+		w.positions = append(w.positions, pos)
+	}
+}
+
+func (w *hcl2Writer) body(list *ast.ObjectList) error {
+
+	attributes := make(map[string]hcltoken.Pos)
+
+	for _, item := range list.Items {
+
+		if len(item.Keys) == 0 {
+			return fmt.Errorf("%s: Missing key", item.Pos())
+		}
+
+		name := keyValue(item.Keys[0])
+
+		if !hclsyntax.ValidIdentifier(name) {
+			return fmt.Errorf("%s: %q is not a valid HCL2 name", item.Pos(), name)
+		}
+
+		object, isObject := item.Val.(*ast.ObjectType)
+
+		if isObject && !item.Assign.IsValid() || len(item.Keys) > 1 {
+
+			if object == nil {
+				return fmt.Errorf("%s: %s has labels but no block", item.Pos(), name)
+			}
+
+			header := name
+
+			for _, label := range item.Keys[1:] {
+				header += " " + hcl2Quote(keyValue(label))
+			}
+
+			if len(object.List.Items) == 0 {
+				w.line(item.Pos(), "%s {}", header)
+				continue
+			}
+
+			w.line(item.Pos(), "%s {", header)
+			w.indent++
+
+			if err := w.body(object.List); err != nil {
+				return err
+			}
+
+			w.indent--
+			w.line(object.Rbrace, "}")
+			continue
+		}
+
+		if previous, ok := attributes[name]; ok {
+			return fmt.Errorf("%s: %s is already defined at %s, and HCL2 doesn't allow attributes to be redefined", item.Pos(), name, previous)
+		}
+
+		attributes[name] = item.Pos()
+
+		value, err := w.expression(item.Val)
+
+		if err != nil {
+			return err
+		}
+
+		w.line(item.Pos(), "%s = %s", name, value)
+	}
+
+	return nil
+}
+
+func (w *hcl2Writer) expression(node ast.Node) (string, error) {
+
+	switch n := node.(type) {
+
+	case *ast.LiteralType:
+
+		switch n.Token.Type {
+		case hcltoken.STRING, hcltoken.HEREDOC:
+			// HCL has no template directives, so any that appear to be in a
+			// string are literal
+			return strings.Replace(strings.TrimSuffix(n.Token.Text, "\n"), "%{", "%%{", -1), nil
+		}
+
+		return n.Token.Text, nil
+
+	case *ast.ListType:
+
+		values := []string{}
+		multiLine := false
+
+		for _, v := range n.List {
+
+			value, err := w.expression(v)
+
+			if err != nil {
+				return "", err
+			}
+
+			multiLine = multiLine || strings.Contains(value, "\n")
+			values = append(values, value)
+		}
+
+		if !multiLine {
+			return "[" + strings.Join(values, ", ") + "]", nil
+		}
+
+		return "[\n" + indentLines(strings.Join(values, ",\n"), 1) + ",\n]", nil
+
+	case *ast.ObjectType:
+		return w.object(n.List)
+	}
+
+	return "", fmt.Errorf("%s: Unexpected value %T", node.Pos(), node)
+}
+
+// object writes an object value, in which blocks become nested objects
+func (w *hcl2Writer) object(list *ast.ObjectList) (string, error) {
+
+	if len(list.Items) == 0 {
+		return "{}", nil
+	}
+
+	lines := []string{}
+
+	for _, item := range list.Items {
+
+		if len(item.Keys) == 0 {
+			return "", fmt.Errorf("%s: Missing key", item.Pos())
+		}
+
+		value, err := w.expression(item.Val)
+
+		if err != nil {
+			return "", err
+		}
+
+		for i := len(item.Keys) - 1; i > 0; i-- {
+			value = "{\n" + indentLines(hcl2Quote(keyValue(item.Keys[i]))+" = "+value, 1) + "\n}"
+		}
+
+		lines = append(lines, hcl2Key(keyValue(item.Keys[0]))+" = "+value)
+	}
+
+	return "{\n" + indentLines(strings.Join(lines, "\n"), 1) + "\n}", nil
+}
+
+// indentLines indents every line except the content and end of heredocs
+func indentLines(s string, indent int) string {
+
+	lines := strings.Split(s, "\n")
+	heredoc := ""
+
+	for i, line := range lines {
+
+		if heredoc != "" {
+			if strings.TrimSpace(line) == heredoc {
+				heredoc = ""
+			}
+			continue
+		}
+
+		if matches := heredocMatcher.FindStringSubmatch(line); matches != nil {
+			heredoc = matches[1]
+		}
+
+		lines[i] = strings.Repeat(" ", indent*hclIndentSize) + line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func hcl2Key(key string) string {
+
+	if hclsyntax.ValidIdentifier(key) {
+		return key
+	}
+
+	return hcl2Quote(key)
+}
+
+func hcl2Quote(s string) string {
+
+	s = strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+		"${", "$${",
+		"%{", "%%{",
+	).Replace(s)
+
+	return `"` + s + `"`
+}
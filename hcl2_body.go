@@ -0,0 +1,196 @@
+package scl
+
+import (
+	hcltoken "github.com/hashicorp/hcl/hcl/token"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// hcl2Positions holds the SCL source position of each line of HCL2 output
+type hcl2Positions []hcltoken.Pos
+
+func (p hcl2Positions) lookup(fileName string, line int) (hcltoken.Pos, bool) {
+
+	if fileName != hcl2FileName || line < 1 || line > len(p) {
+		return hcltoken.Pos{}, false
+	}
+
+	return p[line-1], true
+}
+
+// pos moves a position in the HCL2 output to the SCL source. Columns are
+// those of the SCL line, and byte offsets are not kept.
+func (p hcl2Positions) pos(fileName string, pos hcl.Pos) (string, hcl.Pos) {
+
+	source, ok := p.lookup(fileName, pos.Line)
+
+	if !ok {
+		return fileName, pos
+	}
+
+	return source.Filename, hcl.Pos{Line: source.Line, Column: source.Column}
+}
+
+func (p hcl2Positions) rng(r hcl.Range) hcl.Range {
+
+	fileName, start := p.pos(r.Filename, r.Start)
+	endFileName, end := p.pos(r.Filename, r.End)
+
+	// A block can end in a different file to the one it starts in, when its
+	// content comes from a mixin
+	if endFileName != fileName || end.Line < start.Line {
+		end = start
+	}
+
+	return hcl.Range{Filename: fileName, Start: start, End: end}
+}
+
+func (p hcl2Positions) rngPtr(r *hcl.Range) *hcl.Range {
+
+	if r == nil {
+		return nil
+	}
+
+	mapped := p.rng(*r)
+	return &mapped
+}
+
+func (p hcl2Positions) diagnostics(diags hcl.Diagnostics) hcl.Diagnostics {
+
+	if diags == nil {
+		return nil
+	}
+
+	out := make(hcl.Diagnostics, len(diags))
+
+	for i, diag := range diags {
+		mapped := *diag
+		mapped.Subject = p.rngPtr(diag.Subject)
+		mapped.Context = p.rngPtr(diag.Context)
+		out[i] = &mapped
+	}
+
+	return out
+}
+
+/*
+hcl2Body wraps the body of the HCL2 output so that every range and diagnostic
+it produces, including those of nested blocks and expressions, refers to the
+SCL source rather than to the HCL2 output.
+*/
+type hcl2Body struct {
+	body      hcl.Body
+	positions hcl2Positions
+}
+
+func (b *hcl2Body) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+
+	content, diags := b.body.Content(schema)
+
+	return b.content(content), b.positions.diagnostics(diags)
+}
+
+func (b *hcl2Body) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+
+	content, remain, diags := b.body.PartialContent(schema)
+
+	if remain != nil {
+		remain = &hcl2Body{remain, b.positions}
+	}
+
+	return b.content(content), remain, b.positions.diagnostics(diags)
+}
+
+func (b *hcl2Body) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+
+	attributes, diags := b.body.JustAttributes()
+
+	return b.attributes(attributes), b.positions.diagnostics(diags)
+}
+
+func (b *hcl2Body) MissingItemRange() hcl.Range {
+	return b.positions.rng(b.body.MissingItemRange())
+}
+
+func (b *hcl2Body) content(content *hcl.BodyContent) *hcl.BodyContent {
+
+	if content == nil {
+		return nil
+	}
+
+	out := &hcl.BodyContent{
+		Attributes:       b.attributes(content.Attributes),
+		MissingItemRange: b.positions.rng(content.MissingItemRange),
+	}
+
+	for _, block := range content.Blocks {
+
+		mapped := &hcl.Block{
+			Type:      block.Type,
+			Labels:    block.Labels,
+			Body:      &hcl2Body{block.Body, b.positions},
+			DefRange:  b.positions.rng(block.DefRange),
+			TypeRange: b.positions.rng(block.TypeRange),
+		}
+
+		for _, r := range block.LabelRanges {
+			mapped.LabelRanges = append(mapped.LabelRanges, b.positions.rng(r))
+		}
+
+		out.Blocks = append(out.Blocks, mapped)
+	}
+
+	return out
+}
+
+func (b *hcl2Body) attributes(attributes hcl.Attributes) hcl.Attributes {
+
+	if attributes == nil {
+		return nil
+	}
+
+	out := make(hcl.Attributes, len(attributes))
+
+	for name, attribute := range attributes {
+		out[name] = &hcl.Attribute{
+			Name:      attribute.Name,
+			Expr:      &hcl2Expression{attribute.Expr, b.positions},
+			Range:     b.positions.rng(attribute.Range),
+			NameRange: b.positions.rng(attribute.NameRange),
+		}
+	}
+
+	return out
+}
+
+// hcl2Expression wraps an expression in the HCL2 output in the same way as
+// hcl2Body. Variable traversals keep their HCL2 output ranges.
+type hcl2Expression struct {
+	expr      hcl.Expression
+	positions hcl2Positions
+}
+
+func (e *hcl2Expression) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+
+	value, diags := e.expr.Value(ctx)
+
+	return value, e.positions.diagnostics(diags)
+}
+
+func (e *hcl2Expression) Variables() []hcl.Traversal {
+	return e.expr.Variables()
+}
+
+func (e *hcl2Expression) Range() hcl.Range {
+	return e.positions.rng(e.expr.Range())
+}
+
+func (e *hcl2Expression) StartRange() hcl.Range {
+	return e.positions.rng(e.expr.StartRange())
+}
+
+// UnwrapExpression lets functions such as hcl.ExprList see the underlying
+// expression.
+func (e *hcl2Expression) UnwrapExpression() hcl.Expression {
+	return e.expr
+}
@@ -0,0 +1,118 @@
+package scl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserCanProduceHCL2(t *testing.T) {
+
+	for cycle, input := range []struct {
+		fileName string
+		hcl2     string
+		err      error
+	}{
+		{
+			fileName: "fixtures/valid/basic.scl",
+			hcl2: `wrapper {
+  inner = "yes"
+  another {
+    yet_another = "123"
+  }
+  inner "no" {}
+}
+`,
+		},
+		{
+			fileName: "fixtures/valid/heredoc.scl",
+			hcl2: `container {
+  foo = <<EOF
+bar
+    indent
+baz
+EOF
+  bar = <<DOC
+	hello
+		DOC
+}
+`,
+		},
+		{
+			fileName: "fixtures/valid/mixin-array-calls.scl",
+			hcl2: `a0 = [1, 2, 3]
+a1 = [4, 5, 6]
+`,
+		},
+		{
+			fileName: "fixtures/valid/repeated-blocks.scl",
+			hcl2: `service "web" {
+  port = 80
+  listener {
+    protocol = "http"
+  }
+  listener {
+    protocol = "https"
+  }
+}
+service "api" {
+  port = 8080
+}
+service "web" {
+  port = 81
+  tags = ["a", "b"]
+}
+`,
+		},
+		{
+			fileName: "fixtures/valid/optional-arguments.scl",
+			err:      fmt.Errorf("fixtures/valid/optional-arguments.scl:2:5: required is already defined at fixtures/valid/optional-arguments.scl:2:5, and HCL2 doesn't allow attributes to be redefined"),
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		p := newMockParser(t)
+		require.Nil(t, p.Parse(input.fileName))
+
+		hcl2, err := p.HCL2(true)
+
+		require.Equal(t, input.err, err)
+
+		if input.err == nil {
+			require.Equal(t, input.hcl2, string(hcl2))
+		}
+	}
+}
+
+func Test_HCL2OutputEscapesTemplateDirectives(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", `value = "%{ if x }"`, time.Now())
+
+	p, err := NewParser(fs)
+	require.Nil(t, err)
+	require.Nil(t, p.Parse("main.scl"))
+
+	hcl2, err := p.HCL2(true)
+	require.Nil(t, err)
+	require.Equal(t, "value = \"%%{ if x }\"\n", string(hcl2))
+}
+
+func Test_HCL2ErrorsAreReportedAgainstTheSCLSource(t *testing.T) {
+
+	p := newMockParser(t)
+	require.Nil(t, p.Parse("fixtures/valid/mixin-declaration.scl"))
+
+	src, positions, err := p.hcl2()
+	require.Nil(t, err)
+
+	// Break the line written for nestedLiteral
+	src = []byte(strings.Replace(string(src), `nestedLiteral = "world"`, `nestedLiteral = "world`, 1))
+
+	_, err = parseHCL2(src, positions)
+	require.NotNil(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "fixtures/valid/mixin-declaration.scl:14: "), err.Error())
+}
@@ -0,0 +1,20 @@
+package scl
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/hcl"
+)
+
+// JSON decodes the output with the HCL decoder, so that it has exactly the
+// shape a program decoding the HCL into an interface{} would see
+func (p *parser) JSON() ([]byte, error) {
+
+	value := map[string]interface{}{}
+
+	if err := hcl.DecodeObject(&value, p.ast); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(value, "", "  ")
+}
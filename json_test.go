@@ -0,0 +1,170 @@
+package scl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserCanProduceJSON(t *testing.T) {
+
+	for cycle, input := range []struct {
+		fileName string
+		json     string
+		err      string
+	}{
+		{
+			fileName: "fixtures/valid/comments.scl",
+			json: `{
+  "block": [
+    {
+      "value": 1
+    }
+  ]
+}`,
+		},
+		{
+			fileName: "fixtures/valid/heredoc.scl",
+			json: `{
+  "container": [
+    {
+      "bar": "\thello\n\t\t",
+      "foo": "bar\n    indent\nbaz\n"
+    }
+  ]
+}`,
+		},
+		{
+			fileName: "fixtures/valid/mixin-array-calls.scl",
+			json: `{
+  "a0": [
+    1,
+    2,
+    3
+  ],
+  "a1": [
+    4,
+    5,
+    6
+  ]
+}`,
+		},
+		{
+			fileName: "fixtures/valid/optional-arguments.scl",
+			json: `{
+  "optional": "non-default",
+  "required": "2"
+}`,
+		},
+		{
+			fileName: "fixtures/valid/recursion.scl",
+			json: `{
+  "wrapper": [
+    {
+      "decl": [
+        {
+          "GET": [
+            {
+              "/base/default": [
+                {
+                  "parent_id": 999
+                }
+              ]
+            }
+          ]
+        }
+      ],
+      "route": [
+        {
+          "sub0": [
+            {
+              "decl": [
+                {
+                  "GET": [
+                    {
+                      "/base/sub0/default": [
+                        {
+                          "parent_id": 0
+                        }
+                      ]
+                    }
+                  ]
+                }
+              ],
+              "id": 0,
+              "parent_id": 999,
+              "route": [
+                {
+                  "sub1": [
+                    {
+                      "decl": [
+                        {
+                          "GET": [
+                            {
+                              "/base/sub0/sub1/default": [
+                                {
+                                  "parent_id": 1
+                                }
+                              ]
+                            }
+                          ]
+                        }
+                      ],
+                      "id": 1,
+                      "parent_id": 0,
+                      "route": [
+                        {
+                          "sub2": [
+                            {
+                              "decl": [
+                                {
+                                  "GET": [
+                                    {
+                                      "/base/sub0/sub1/sub2/default": [
+                                        {
+                                          "parent_id": 2
+                                        }
+                                      ]
+                                    }
+                                  ]
+                                }
+                              ],
+                              "id": 2,
+                              "parent_id": 1
+                            }
+                          ]
+                        }
+                      ]
+                    }
+                  ]
+                }
+              ]
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`,
+		},
+		{
+			fileName: "fixtures/valid/basic.scl",
+			err:      "At -: root.wrapper[0].inner: unknown type for string *ast.ObjectList",
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		p := newMockParser(t)
+		require.Nil(t, p.Parse(input.fileName))
+
+		json, err := p.JSON()
+
+		if input.err != "" {
+			require.EqualError(t, err, input.err)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, input.json, string(json))
+	}
+}
@@ -0,0 +1,327 @@
+package scl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A LintRule is a kind of problem that the Linter looks for
+type LintRule string
+
+const (
+	// LintUnusedMixin reports mixins that are declared but never called
+	LintUnusedMixin LintRule = "unused-mixin"
+
+	// LintUnusedParam reports mixin parameters that are never used by any
+	// call of the mixin
+	LintUnusedParam LintRule = "unused-param"
+
+	// LintUnusedVariable reports variables that are declared but never used
+	LintUnusedVariable LintRule = "unused-variable"
+
+	// LintShadowedVariable reports := declarations that hide a variable of
+	// the same name from an outer scope
+	LintShadowedVariable LintRule = "shadowed-variable"
+
+	// LintRedefinedMixin reports mixins declared twice in the same scope,
+	// where the second silently replaces the first
+	LintRedefinedMixin LintRule = "redefined-mixin"
+
+	// LintUnreachableBody reports __body__() calls in mixins that are never
+	// called with a body
+	LintUnreachableBody LintRule = "unreachable-body"
+
+	// LintPrivateMixin reports mixins whose names start with an underscore
+	// being called from a file other than the one that declares them
+	LintPrivateMixin LintRule = "private-mixin"
+)
+
+// LintRules lists every rule the Linter knows
+var LintRules = []LintRule{
+	LintUnusedMixin,
+	LintUnusedParam,
+	LintUnusedVariable,
+	LintShadowedVariable,
+	LintRedefinedMixin,
+	LintUnreachableBody,
+	LintPrivateMixin,
+}
+
+// A LintIssue is a problem found by the Linter, at a line of SCL source
+type LintIssue struct {
+	Rule    LintRule
+	File    string
+	Line    int
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", i.File, i.Line, i.Message, i.Rule)
+}
+
+// LintIssues is a list of issues, ordered by file and line
+type LintIssues []LintIssue
+
+/*
+A Linter looks for problems in SCL that compiles, but probably doesn't do what
+its author meant. Since SCL's scoping is dynamic (a mixin sees the variables
+of wherever it's called from) the Linter works by compiling the file and
+watching what happens, rather than by reading the source alone. A mixin or
+variable counts as used if any part of the compilation uses it; mixins that
+are declared inside a mixin that's never called aren't seen at all. The
+@test blocks of every file are compiled too, so a mixin or variable that's
+only used by the tests is still used.
+
+Every rule is enabled by default.
+*/
+type Linter interface {
+	SetParam(name, value string)
+	AddIncludePath(name string)
+	Enable(rules ...LintRule)
+	Disable(rules ...LintRule)
+	Lint(fileName string) (LintIssues, error)
+}
+
+type linter struct {
+	fs           FileSystem
+	params       [][2]string
+	includePaths []string
+	disabled     map[LintRule]bool
+}
+
+/*
+NewLinter creates a Linter that reads files using the FileSystem provided, in
+the same way as a Parser.
+*/
+func NewLinter(fs FileSystem) (Linter, error) {
+	return &linter{
+		fs:       fs,
+		disabled: make(map[LintRule]bool),
+	}, nil
+}
+
+func (l *linter) SetParam(name, value string) {
+	l.params = append(l.params, [2]string{name, value})
+}
+
+func (l *linter) AddIncludePath(name string) {
+	l.includePaths = append(l.includePaths, name)
+}
+
+func (l *linter) Enable(rules ...LintRule) {
+	for _, rule := range rules {
+		delete(l.disabled, rule)
+	}
+}
+
+func (l *linter) Disable(rules ...LintRule) {
+	for _, rule := range rules {
+		l.disabled[rule] = true
+	}
+}
+
+/*
+Lint compiles a file and returns the issues in it and in every file it
+includes. If the file can't be compiled, the issues found before the error
+are returned along with it.
+*/
+func (l *linter) Lint(fileName string) (LintIssues, error) {
+
+	p0, err := NewParser(l.fs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := p0.(*parser)
+	p.lint = newLintRecorder()
+	p.tests = &testRecorder{}
+
+	for _, param := range l.params {
+		p.SetParam(param[0], param[1])
+	}
+
+	for _, includePath := range l.includePaths {
+		p.AddIncludePath(includePath)
+	}
+
+	if err = p.Parse(fileName); err == nil {
+		p.tests.run(p)
+	}
+
+	issues := LintIssues{}
+
+	for _, issue := range p.lint.issues() {
+		if !l.disabled[issue.Rule] {
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues, err
+}
+
+// lintDeclaration is a mixin, parameter or variable that should be used. A
+// mixin is used if it's ever called, and a variable is used if any of the
+// variables created by its declaration is used.
+type lintDeclaration struct {
+	issue     LintIssue
+	used      bool
+	variables []*variable
+}
+
+// lintRecorder watches a compilation for lint issues. All of its methods do
+// nothing on a nil recorder, which is what a Parser normally has.
+type lintRecorder struct {
+	found        LintIssues
+	seen         map[string]bool
+	declarations []*lintDeclaration
+	index        map[string]*lintDeclaration
+	sites        map[*variable]*scannerLine
+	mixins       map[*scope]map[string]*scannerLine
+}
+
+func newLintRecorder() *lintRecorder {
+	return &lintRecorder{
+		seen:   make(map[string]bool),
+		index:  make(map[string]*lintDeclaration),
+		sites:  make(map[*variable]*scannerLine),
+		mixins: make(map[*scope]map[string]*scannerLine),
+	}
+}
+
+func (r *lintRecorder) report(rule LintRule, branch *scannerLine, message string, args ...interface{}) {
+
+	issue := LintIssue{rule, branch.file, branch.line, fmt.Sprintf(message, args...)}
+
+	// Mixins are compiled once for each call, so issues inside them repeat
+	if key := issue.String(); !r.seen[key] {
+		r.seen[key] = true
+		r.found = append(r.found, issue)
+	}
+}
+
+func (r *lintRecorder) declaration(rule LintRule, branch *scannerLine, name, message string, args ...interface{}) *lintDeclaration {
+
+	key := fmt.Sprintf("%s %s %s", rule, branch, name)
+
+	if d, ok := r.index[key]; ok {
+		return d
+	}
+
+	d := &lintDeclaration{issue: LintIssue{rule, branch.file, branch.line, fmt.Sprintf(message, args...)}}
+
+	r.index[key] = d
+	r.declarations = append(r.declarations, d)
+
+	return d
+}
+
+func (r *lintRecorder) declareVariable(branch *scannerLine, v, shadowed *variable) {
+
+	if r == nil {
+		return
+	}
+
+	d := r.declaration(LintUnusedVariable, branch, v.name, "Variable $%s is declared but never used", v.name)
+	d.variables = append(d.variables, v)
+	r.sites[v] = branch
+
+	if shadowed == nil {
+		return
+	}
+
+	if site, ok := r.sites[shadowed]; ok {
+		r.report(LintShadowedVariable, branch, "$%s shadows the variable declared at %s", v.name, site)
+	} else {
+		r.report(LintShadowedVariable, branch, "$%s shadows a parameter or variable from an outer scope", v.name)
+	}
+}
+
+func (r *lintRecorder) declareParam(mx *mixin, mixinName, name string, v *variable) {
+
+	if r == nil {
+		return
+	}
+
+	d := r.declaration(LintUnusedParam, mx.declaration, name, "Parameter $%s of mixin %s is never used", name, mixinName)
+	d.variables = append(d.variables, v)
+}
+
+func (r *lintRecorder) declareMixin(branch *scannerLine, name string, s *scope) {
+
+	if r == nil {
+		return
+	}
+
+	r.declaration(LintUnusedMixin, branch, name, "Mixin %s is declared but never used", name)
+
+	// Mixins inherited from an outer scope, or from the scope of the mixin a
+	// body is passed to, are meant to be overridden. A file included twice
+	// declares its mixins twice without redefining them.
+	if r.mixins[s] == nil {
+		r.mixins[s] = make(map[string]*scannerLine)
+	}
+
+	if previous, ok := r.mixins[s][name]; ok && previous.String() != branch.String() {
+		r.report(LintRedefinedMixin, branch, "Mixin %s is already declared in this scope at %s", name, previous)
+	}
+
+	r.mixins[s][name] = branch
+}
+
+func (r *lintRecorder) callMixin(branch *scannerLine, name string, mx *mixin) {
+
+	if r == nil {
+		return
+	}
+
+	r.declaration(LintUnusedMixin, mx.declaration, name, "").used = true
+
+	if strings.HasPrefix(name, "_") && branch.file != mx.declaration.file {
+		r.report(LintPrivateMixin, branch, "Mixin %s is private to %s", name, mx.declaration.file)
+	}
+}
+
+func (r *lintRecorder) callBody(branch *scannerLine, hasBody bool) {
+
+	if r == nil {
+		return
+	}
+
+	d := r.declaration(LintUnreachableBody, branch, "", "__body__() is never reached, since no call of its mixin has a body")
+	d.used = d.used || hasBody
+}
+
+func (r *lintRecorder) issues() LintIssues {
+
+	if r == nil {
+		return nil
+	}
+
+	issues := append(LintIssues{}, r.found...)
+
+	for _, d := range r.declarations {
+
+		used := d.used
+
+		for _, v := range d.variables {
+			used = used || v.uses > 0
+		}
+
+		if !used {
+			issues = append(issues, d.issue)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+
+		return issues[i].Line < issues[j].Line
+	})
+
+	return issues
+}
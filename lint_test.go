@@ -0,0 +1,152 @@
+package scl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ALinterFindsIssuesInValidSCL(t *testing.T) {
+
+	for cycle, test := range []struct {
+		files    map[string]string
+		disabled []LintRule
+		expected LintIssues
+	}{
+		{
+			files: map[string]string{
+				"main.scl": `@used($a, $b)
+    a = $a
+
+@unused()
+    x = 1
+
+used(1, 2)`,
+			},
+			expected: LintIssues{
+				{LintUnusedParam, "main.scl", 1, "Parameter $b of mixin used is never used"},
+				{LintUnusedMixin, "main.scl", 4, "Mixin unused is declared but never used"},
+			},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `$a = 1
+$b = 2
+block
+    $a := 3
+    value = $a`,
+			},
+			expected: LintIssues{
+				{LintUnusedVariable, "main.scl", 1, "Variable $a is declared but never used"},
+				{LintUnusedVariable, "main.scl", 2, "Variable $b is declared but never used"},
+				{LintShadowedVariable, "main.scl", 4, "$a shadows the variable declared at main.scl:1"},
+			},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `@m()
+    a = 1
+
+@m()
+    b = 2
+
+@wrap()
+    block
+        __body__()
+
+m()
+wrap()`,
+			},
+			expected: LintIssues{
+				{LintUnusedMixin, "main.scl", 1, "Mixin m is declared but never used"},
+				{LintRedefinedMixin, "main.scl", 4, "Mixin m is already declared in this scope at main.scl:1"},
+				{LintUnreachableBody, "main.scl", 9, "__body__() is never reached, since no call of its mixin has a body"},
+			},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `include("lib.scl")
+_helper()`,
+				"lib.scl": `@_helper()
+    a = 1`,
+			},
+			expected: LintIssues{
+				{LintPrivateMixin, "main.scl", 2, "Mixin _helper is private to lib.scl"},
+			},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `$a = 1
+@unused()
+    x = 1`,
+			},
+			disabled: []LintRule{LintUnusedVariable},
+			expected: LintIssues{
+				{LintUnusedMixin, "main.scl", 2, "Mixin unused is declared but never used"},
+			},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `@overloadable()
+    a = 1
+
+@base()
+    overloadable()
+
+base()
+    @overloadable()
+        b = 2`,
+			},
+			expected: LintIssues{},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `include("lib.scl")
+$expected = 80
+
+@test "helper"
+    @assert helper(80)
+        port = $expected`,
+				"lib.scl": `@helper($port)
+    port = $port
+
+@test "lib"
+    $unused = 1`,
+			},
+			expected: LintIssues{
+				{LintUnusedVariable, "lib.scl", 5, "Variable $unused is declared but never used"},
+			},
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		fs := newMemoryFileSystem()
+
+		for name, content := range test.files {
+			fs.set(name, content, time.Now())
+		}
+
+		linter, err := NewLinter(fs)
+		require.NoError(t, err)
+
+		linter.Disable(test.disabled...)
+
+		issues, err := linter.Lint("main.scl")
+		require.NoError(t, err)
+		require.Equal(t, test.expected, issues)
+	}
+}
+
+func Test_ALinterReturnsIssuesFoundBeforeAnError(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "$a = 1\nvalue = $b", time.Now())
+
+	linter, err := NewLinter(fs)
+	require.NoError(t, err)
+
+	issues, err := linter.Lint("main.scl")
+	require.Error(t, err)
+	require.Equal(t, LintIssues{{LintUnusedVariable, "main.scl", 1, "Variable $a is declared but never used"}}, issues)
+}
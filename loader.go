@@ -0,0 +1,271 @@
+package scl
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+A Loader decodes an SCL file into a configuration value and keeps it up to
+date for the lifetime of a long-running program. Whenever the file, or any
+file it includes, changes, the Loader parses and decodes it again into a new
+value, validates it, and atomically replaces the value returned by Config().
+
+If a reload fails, because the SCL can't be parsed, the HCL can't be decoded
+or the validator rejects the result, the last good configuration is kept and
+the error is passed to subscribers. A failed reload is only retried once one
+of the files it read changes again.
+
+Load() parses and decodes the file immediately, and must be called once
+before the configuration is available. After that, Refresh() reloads the
+configuration only if one of the files it depends on has changed, reporting
+whether it tried, and Watch() calls Refresh() in the background at the given
+interval until Close() is called.
+
+Subscribers are notified after every reload attempt, either by callback or by
+channel. Callbacks receive the current configuration and any error, and must
+not call Load() or Refresh() themselves. Channels only receive successfully
+loaded configurations, and a send is skipped if the channel isn't ready to
+receive it.
+*/
+type Loader interface {
+	SetParam(name, value string)
+	AddIncludePath(name string)
+	SetValidator(validator func(config interface{}) error)
+	Subscribe(callback func(config interface{}, err error))
+	Notify(ch chan<- interface{})
+	Load() error
+	Refresh() (bool, error)
+	Watch(interval time.Duration)
+	Config() interface{}
+	Close()
+}
+
+type loader struct {
+	fs           FileSystem
+	fileName     string
+	configType   reflect.Type
+	params       [][2]string
+	includePaths []string
+	validator    func(config interface{}) error
+	callbacks    []func(config interface{}, err error)
+	channels     []chan<- interface{}
+	cache        *Cache
+	parser       Parser
+	loaded       bool
+	config       atomic.Value
+	mutex        sync.Mutex
+	done         chan struct{}
+}
+
+/*
+NewLoader creates a Loader for the named file, read from the given FileSystem.
+The config argument is a pointer to a value of the type each load is decoded
+into, such as a pointer to a struct with `hcl` tags. Every load is decoded
+into a new value of the same type, and the first one that succeeds is copied
+into config, which becomes the initial configuration; it's never changed by a
+load that fails, or by later reloads.
+*/
+func NewLoader(fs FileSystem, fileName string, config interface{}) (Loader, error) {
+
+	t := reflect.TypeOf(config)
+
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("Config must be a pointer, not %T", config)
+	}
+
+	l := &loader{
+		fs:         fs,
+		fileName:   fileName,
+		configType: t.Elem(),
+		cache:      NewCache(),
+	}
+
+	l.config.Store(config)
+
+	return l, nil
+}
+
+func (l *loader) SetParam(name, value string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.params = append(l.params, [2]string{name, value})
+}
+
+func (l *loader) AddIncludePath(name string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.includePaths = append(l.includePaths, name)
+}
+
+func (l *loader) SetValidator(validator func(config interface{}) error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.validator = validator
+}
+
+func (l *loader) Subscribe(callback func(config interface{}, err error)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.callbacks = append(l.callbacks, callback)
+}
+
+func (l *loader) Notify(ch chan<- interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.channels = append(l.channels, ch)
+}
+
+func (l *loader) Config() interface{} {
+	return l.config.Load()
+}
+
+func (l *loader) Load() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.load()
+}
+
+func (l *loader) Refresh() (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.parser != nil {
+		if stale, err := l.parser.Stale(); err == nil && !stale {
+			return false, nil
+		}
+	}
+
+	return true, l.load()
+}
+
+func (l *loader) Watch(interval time.Duration) {
+
+	l.mutex.Lock()
+
+	if l.done != nil {
+		close(l.done)
+	}
+
+	done := make(chan struct{})
+	l.done = done
+
+	l.mutex.Unlock()
+
+	go func() {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				l.Refresh()
+			}
+		}
+	}()
+}
+
+func (l *loader) Close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.done != nil {
+		close(l.done)
+		l.done = nil
+	}
+}
+
+func (l *loader) load() (err error) {
+
+	var config interface{}
+
+	defer func() {
+		l.notify(config, err)
+	}()
+
+	parser, err := NewParser(l.fs)
+
+	if err != nil {
+		return err
+	}
+
+	parser.SetCache(l.cache)
+
+	for _, includePath := range l.includePaths {
+		parser.AddIncludePath(includePath)
+	}
+
+	for _, param := range l.params {
+		parser.SetParam(param[0], param[1])
+	}
+
+	// The parser is kept even if it fails, so that the files it read are
+	// watched for a fix
+	l.parser = parser
+
+	if err := parser.Parse(l.fileName); err != nil {
+		return err
+	}
+
+	// Each load is decoded into a new value, so that a failure never leaves
+	// part of it in the configuration
+	value := reflect.New(l.configType).Interface()
+
+	if err := decodeParser(value, parser); err != nil {
+		return fmt.Errorf("[%s] %s", l.fileName, err.Error())
+	}
+
+	if l.validator != nil {
+		if err := l.validator(value); err != nil {
+			return fmt.Errorf("[%s] Invalid configuration: %s", l.fileName, err.Error())
+		}
+	}
+
+	// The first good configuration is copied into the value given to
+	// NewLoader; after that, readers may be using it
+	if !l.loaded {
+		initial := l.config.Load()
+		reflect.ValueOf(initial).Elem().Set(reflect.ValueOf(value).Elem())
+		value = initial
+	}
+
+	l.config.Store(value)
+	l.loaded = true
+	config = value
+
+	return nil
+}
+
+func (l *loader) notify(config interface{}, err error) {
+
+	if err != nil {
+		config = l.config.Load()
+	}
+
+	for _, callback := range l.callbacks {
+		callback(config, err)
+	}
+
+	if err != nil {
+		return
+	}
+
+	for _, ch := range l.channels {
+		select {
+		case ch <- config:
+		default:
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package scl
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type loaderConfig struct {
+	Port int    `hcl:"port"`
+	Name string `hcl:"name"`
+}
+
+func Test_ALoaderReloadsChangedFiles(t *testing.T) {
+
+	then := time.Date(2016, 11, 8, 15, 0, 0, 0, time.UTC)
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "include(\"lib\")\nport = 80", then)
+	fs.set("lib.scl", "name = \"web\"", then)
+
+	initial := &loaderConfig{}
+
+	l, err := NewLoader(fs, "main.scl", initial)
+	require.Nil(t, err)
+
+	var (
+		notified []interface{}
+		errors   []error
+	)
+
+	l.Subscribe(func(config interface{}, err error) {
+		notified = append(notified, config)
+		errors = append(errors, err)
+	})
+
+	ch := make(chan interface{}, 1)
+	l.Notify(ch)
+
+	require.Nil(t, l.Load())
+	require.True(t, initial == l.Config())
+	require.Equal(t, &loaderConfig{80, "web"}, l.Config())
+	require.Equal(t, initial, <-ch)
+
+	reloaded, err := l.Refresh()
+	require.Nil(t, err)
+	require.False(t, reloaded)
+
+	fs.set("lib.scl", "name = \"api\"", then.Add(time.Second))
+
+	reloaded, err = l.Refresh()
+	require.Nil(t, err)
+	require.True(t, reloaded)
+
+	require.Equal(t, &loaderConfig{80, "api"}, l.Config())
+	require.Equal(t, &loaderConfig{80, "web"}, initial, "A loaded config should never be modified")
+	require.Equal(t, l.Config(), <-ch)
+
+	require.Equal(t, []interface{}{initial, l.Config()}, notified)
+	require.Equal(t, []error{nil, nil}, errors)
+}
+
+func Test_ALoaderKeepsTheLastGoodConfig(t *testing.T) {
+
+	then := time.Date(2016, 11, 8, 15, 0, 0, 0, time.UTC)
+
+	for cycle, test := range []struct {
+		content   string
+		validator func(config interface{}) error
+		err       string
+	}{
+		{
+			content: "port = $undeclared",
+			err:     "[main.scl:1] Unknown variable '$undeclared'",
+		},
+		{
+			content: "port = \"eighty\"",
+			err:     "[main.scl] strconv.ParseInt: parsing \"eighty\": invalid syntax",
+		},
+		{
+			content: "port = 0",
+			validator: func(config interface{}) error {
+				if config.(*loaderConfig).Port == 0 {
+					return fmt.Errorf("A port is required")
+				}
+				return nil
+			},
+			err: "[main.scl] Invalid configuration: A port is required",
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		fs := newMemoryFileSystem()
+		fs.set("main.scl", "port = 80", then)
+
+		l, err := NewLoader(fs, "main.scl", &loaderConfig{})
+		require.Nil(t, err)
+
+		if test.validator != nil {
+			l.SetValidator(test.validator)
+		}
+
+		require.Nil(t, l.Load())
+		good := l.Config()
+
+		var notifiedErr error
+
+		l.Subscribe(func(config interface{}, err error) {
+			require.True(t, good == config)
+			notifiedErr = err
+		})
+
+		ch := make(chan interface{}, 1)
+		l.Notify(ch)
+
+		fs.set("main.scl", test.content, then.Add(time.Second))
+
+		reloaded, err := l.Refresh()
+		require.True(t, reloaded)
+		require.NotNil(t, err)
+		require.Equal(t, test.err, err.Error())
+		require.Equal(t, err, notifiedErr)
+		require.True(t, good == l.Config())
+		require.Len(t, ch, 0)
+
+		// The failure isn't retried until something changes
+		reloaded, err = l.Refresh()
+		require.False(t, reloaded)
+		require.Nil(t, err)
+	}
+}
+
+func Test_ALoaderReloadsAFileThatComesBack(t *testing.T) {
+
+	then := time.Date(2016, 11, 8, 15, 0, 0, 0, time.UTC)
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "port = 80", then)
+
+	l, err := NewLoader(fs, "main.scl", &loaderConfig{})
+	require.Nil(t, err)
+	require.Nil(t, l.Load())
+
+	fs.remove("main.scl")
+
+	reloaded, err := l.Refresh()
+	require.True(t, reloaded)
+	require.NotNil(t, err)
+	require.Equal(t, &loaderConfig{Port: 80}, l.Config())
+
+	// The file is only retried once it's back
+	reloaded, err = l.Refresh()
+	require.False(t, reloaded)
+	require.Nil(t, err)
+
+	fs.set("main.scl", "port = 8080", then.Add(time.Second))
+
+	reloaded, err = l.Refresh()
+	require.True(t, reloaded)
+	require.Nil(t, err)
+	require.Equal(t, &loaderConfig{Port: 8080}, l.Config())
+}
+
+func Test_AFailedFirstLoadLeavesNothingBehind(t *testing.T) {
+
+	then := time.Date(2016, 11, 8, 15, 0, 0, 0, time.UTC)
+
+	type config struct {
+		Port  int      `hcl:"port"`
+		Names []string `hcl:"names"`
+	}
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", `names = ["a"]`, then)
+
+	initial := &config{}
+
+	l, err := NewLoader(fs, "main.scl", initial)
+	require.Nil(t, err)
+
+	l.SetValidator(func(c interface{}) error {
+		if c.(*config).Port == 0 {
+			return fmt.Errorf("A port is required")
+		}
+		return nil
+	})
+
+	require.EqualError(t, l.Load(), "[main.scl] Invalid configuration: A port is required")
+	require.Equal(t, &config{}, l.Config())
+
+	fs.set("main.scl", "names = [\"b\"]\nport = 80", then.Add(time.Second))
+
+	require.Nil(t, l.Load())
+	require.True(t, initial == l.Config())
+	require.Equal(t, &config{Port: 80, Names: []string{"b"}}, l.Config())
+}
+
+func Test_ALoaderCanWatchForChanges(t *testing.T) {
+
+	then := time.Date(2016, 11, 8, 15, 0, 0, 0, time.UTC)
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "port = $port", then)
+
+	l, err := NewLoader(fs, "main.scl", &loaderConfig{})
+	require.Nil(t, err)
+
+	l.SetParam("port", "80")
+	require.Nil(t, l.Load())
+
+	ch := make(chan interface{}, 1)
+	l.Notify(ch)
+
+	l.Watch(time.Millisecond)
+	defer l.Close()
+
+	fs.set("main.scl", "port = 8080", then.Add(time.Second))
+
+	select {
+	case config := <-ch:
+		require.Equal(t, &loaderConfig{Port: 8080}, config)
+		require.Equal(t, config, l.Config())
+	case <-time.After(time.Second):
+		t.Fatal("The change wasn't picked up")
+	}
+}
+
+func Test_ALoaderRequiresAPointer(t *testing.T) {
+	_, err := NewLoader(newMemoryFileSystem(), "main.scl", loaderConfig{})
+	require.Equal(t, fmt.Errorf("Config must be a pointer, not scl.loaderConfig"), err)
+}
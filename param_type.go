@@ -0,0 +1,99 @@
+package scl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var quotedStringMatcher = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+var allowedValuesMatcher = regexp.MustCompile(`^(?:"[^"]*"|'[^']*')(?:\s*\|\s*(?:"[^"]*"|'[^']*'))*$`)
+
+/*
+paramType is the type of a mixin parameter or an @param, written after its
+name as in `$port: number`. It's one of number, bool, list or string, or a
+list of the values that are allowed, separated by |, as in `"dev"|"prod"`.
+An empty type allows any value.
+*/
+type paramType string
+
+const (
+	typeBool   paramType = "bool"
+	typeList   paramType = "list"
+	typeNumber paramType = "number"
+	typeString paramType = "string"
+)
+
+func parseParamType(s string) (paramType, error) {
+
+	switch t := paramType(s); t {
+	case typeBool, typeList, typeNumber, typeString:
+		return t, nil
+	}
+
+	if allowedValuesMatcher.MatchString(s) {
+		return paramType(s), nil
+	}
+
+	return "", fmt.Errorf("Unknown type %s", s)
+}
+
+// allowed returns the values a type allows, if it lists them
+func (t paramType) allowed() []string {
+
+	switch t {
+	case "", typeBool, typeList, typeNumber, typeString:
+		return nil
+	}
+
+	return quotedStringMatcher.FindAllString(string(t), -1)
+}
+
+// check returns why a value doesn't have the type. Empty values, which are
+// given by an _ default, always pass. A number or bool may be quoted, since
+// params given by the scl tool always are, so a given "8" is still a number.
+func (t paramType) check(value string) error {
+
+	if t == "" || value == "" {
+		return nil
+	}
+
+	switch t {
+
+	case typeNumber:
+		if _, err := strconv.ParseFloat(unquote(value), 64); err != nil {
+			return fmt.Errorf("must be a number, not %s", value)
+		}
+
+	case typeBool:
+		if v := unquote(value); v != "true" && v != "false" {
+			return fmt.Errorf("must be true or false, not %s", value)
+		}
+
+	case typeList:
+		if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+			return fmt.Errorf("must be a list, not %s", value)
+		}
+
+	case typeString:
+		if unquote(value) == value && !strings.HasPrefix(value, "<<") {
+			return fmt.Errorf("must be a string, not %s", value)
+		}
+
+	default:
+
+		allowed := t.allowed()
+
+		// Either kind of quotes will do
+		for _, a := range allowed {
+			if unquote(value) != value && unquote(value) == unquote(a) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("must be one of %s, not %s", strings.Join(allowed, ", "), value)
+	}
+
+	return nil
+}
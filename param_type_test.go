@@ -0,0 +1,143 @@
+package scl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParamTypesCheckValues(t *testing.T) {
+
+	for cycle, test := range []struct {
+		paramType string
+		value     string
+		err       string
+	}{
+		{paramType: "number", value: "80"},
+		{paramType: "number", value: "-1.5"},
+		{paramType: "number", value: `"eighty"`, err: `must be a number, not "eighty"`},
+		{paramType: "number", value: `"3"`},
+		{paramType: "bool", value: "true"},
+		{paramType: "bool", value: `'false'`},
+		{paramType: "bool", value: `"yes"`, err: `must be true or false, not "yes"`},
+		{paramType: "list", value: `["a", "b"]`},
+		{paramType: "list", value: `"a"`, err: `must be a list, not "a"`},
+		{paramType: "string", value: `'a'`},
+		{paramType: "string", value: "1", err: "must be a string, not 1"},
+		{paramType: `"dev"|"prod"`, value: `"prod"`},
+		{paramType: `"dev"|"prod"`, value: `'dev'`},
+		{paramType: `"dev"|"prod"`, value: `dev`, err: `must be one of "dev", "prod", not dev`},
+		{paramType: `"dev" | 'prod'`, value: `"staging"`, err: `must be one of "dev", 'prod', not "staging"`},
+		{paramType: "number", value: ""},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		pt, err := parseParamType(test.paramType)
+		require.NoError(t, err)
+
+		err = pt.check(test.value)
+
+		if test.err == "" {
+			require.NoError(t, err)
+		} else {
+			require.EqualError(t, err, test.err)
+		}
+	}
+
+	_, err := parseParamType("integer")
+	require.EqualError(t, err, "Unknown type integer")
+}
+
+func Test_AParserChecksTypedMixinArguments(t *testing.T) {
+
+	for cycle, test := range []struct {
+		source string
+		hcl    string
+		err    string
+	}{
+		{
+			source: `@service($name: string, $port: number = 80, $env: "dev"|"prod" = _)
+    service $name
+        port = $port
+
+service("web")
+service("api", 8080, "dev")
+service("worker", 9000, 'prod')`,
+			hcl: `service "web" {
+  port = 80
+}
+service "api" {
+  port = 8080
+}
+service "worker" {
+  port = 9000
+}`,
+		},
+		{
+			source: `@port($port: number)
+    port = $port
+
+block
+    port("eighty")`,
+			err: `[main.scl:5] Argument $port of port must be a number, not "eighty"`,
+		},
+		{
+			source: `$env = "staging"
+
+@deploy($env: "dev"|"prod")
+    env = $env
+
+deploy($env)`,
+			err: `[main.scl:6] Argument $env of deploy must be one of "dev", "prod", not "staging"`,
+		},
+		{
+			source: `@m($enabled: bool = "yes")
+    enabled = $enabled`,
+			err: `[main.scl:1] Argument declaration 1 [enabled]: The default must be true or false, not "yes"`,
+		},
+		{
+			source: `@m($count: integer)
+    count = $count`,
+			err: "[main.scl:1] Argument declaration 1 [count]: Unknown type integer",
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		fs := newMemoryFileSystem()
+		fs.set("main.scl", test.source, time.Now())
+
+		p, err := NewParser(fs)
+		require.NoError(t, err)
+
+		err = p.Parse("main.scl")
+
+		if test.err != "" {
+			require.EqualError(t, err, test.err)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, test.hcl, p.String())
+	}
+}
+
+func Test_MixinDocsIncludeParamTypes(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", `@service($name: string, $port: number = 80, $tags = [])
+    service $name`, time.Now())
+
+	p, err := NewParser(fs)
+	require.NoError(t, err)
+
+	docs, err := p.Documentation("main.scl")
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+
+	require.Equal(t, MixinParams{
+		{Name: "name", Type: "string", Required: true},
+		{Name: "port", Type: "number", Default: "80"},
+		{Name: "tags", Default: "[]"},
+	}, docs[0].Params)
+}
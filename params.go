@@ -0,0 +1,287 @@
+package scl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var paramDeclarationMatcher = regexp.MustCompile(`^\$([a-zA-Z_][a-zA-Z0-9_]*)(?:\s*:\s*([a-z]+|(?:"[^"]*"|'[^']*')(?:\s*\|\s*(?:"[^"]*"|'[^']*'))*))?(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"']+))?(?:\s+("[^"]*"|'[^']*'))?$`)
+
+/*
+A Param is a variable that a file expects to be given with SetParam(), or
+from the environment by the scl tool, declared with an @param directive at
+the top level of the file:
+
+	@param $region "The AWS region to deploy to"
+	@param $environment: "dev"|"prod" = "dev" "The environment"
+	@param $replicas: number = 2
+
+A param with a default isn't required; if it isn't given, it's declared with
+the default. A param can have a type in the same way as a mixin parameter:
+number, bool, list, string, or the values it allows separated by |, which
+are also listed in Allowed. Supplied is whether the param was given, and
+Value is the value it had once its default was applied.
+*/
+type Param struct {
+	Name      string
+	File      string
+	Line      int
+	Reference string
+	Docs      string
+	Default   string
+	Type      string
+	Allowed   []string
+	Required  bool
+	Supplied  bool
+	Value     string
+}
+
+// String describes a param as a line of help
+func (p Param) String() string {
+
+	description := "$" + p.Name
+
+	if p.Type != "" {
+		description += ": " + p.Type
+	}
+
+	if !p.Required {
+		description += " = " + p.Default
+	}
+
+	if p.Docs != "" {
+		description += fmt.Sprintf(" (%s)", p.Docs)
+	}
+
+	return description
+}
+
+// Params is a list of params, in the order they were declared
+type Params []Param
+
+// Missing returns the required params that weren't given
+func (p Params) Missing() Params {
+
+	missing := Params{}
+
+	for _, param := range p {
+		if param.Required && !param.Supplied {
+			missing = append(missing, param)
+		}
+	}
+
+	return missing
+}
+
+func (p *parser) Params() Params {
+	return p.params
+}
+
+// missingParams collects the required params that weren't given, once each,
+// along with the line of the first of them
+type missingParams struct {
+	names []string
+	first *scannerLine
+}
+
+func (m *missingParams) add(name string, branch *scannerLine) {
+
+	for _, n := range m.names {
+		if n == name {
+			return
+		}
+	}
+
+	m.names = append(m.names, name)
+
+	if m.first == nil {
+		m.first = branch
+	}
+}
+
+/*
+declareParams declares the params of a file, and of every file it includes
+by name, before the rest of it is compiled, so that every missing param in
+the include graph is reported at once rather than as an unknown variable
+wherever the first of them is used.
+*/
+func (p *parser) declareParams(tree scannerTree) error {
+
+	scanned := map[string]bool{}
+	missing := &missingParams{}
+
+	if len(tree) > 0 {
+		scanned[tree[0].file] = true
+	}
+
+	if err := p.hoistParams(tree, scanned, missing); err != nil {
+		return err
+	}
+
+	if len(missing.names) > 0 {
+		return p.err(missing.first, "Missing required param(s): %s", strings.Join(missing.names, ", "))
+	}
+
+	return nil
+}
+
+// hoistParams declares the params at the top level of a tree, following any
+// includes whose names don't depend on a variable that isn't declared yet
+func (p *parser) hoistParams(tree scannerTree, scanned map[string]bool, missing *missingParams) error {
+
+	tkn := newTokeniser()
+
+	for _, branch := range tree {
+
+		// Any errors are reported when the line is compiled
+		tokens, err := tkn.tokenise(branch)
+
+		if err != nil || len(tokens) == 0 {
+			continue
+		}
+
+		switch {
+		case tokens[0].kind == tokenDirective && tokens[0].content == directiveParam:
+
+			param, err := p.declareParam(branch, tokens)
+
+			if err != nil {
+				return err
+			}
+
+			if param.Required && !param.Supplied {
+				missing.add("$"+param.Name, branch)
+			}
+
+		case tokens[0].kind == tokenFunctionCall && tokens[0].content == builtinMixinInclude:
+
+			if err := p.hoistIncludedParams(branch, tokens, scanned, missing); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// hoistIncludedParams declares the params of the files an include names. An
+// include that can't be found yet is left to report its error when it's
+// compiled.
+func (p *parser) hoistIncludedParams(branch *scannerLine, tokens []token, scanned map[string]bool, missing *missingParams) error {
+
+	names, err := p.extractValuesFromArgTokens(branch, tokens[1:], p.rootScope)
+
+	if err != nil {
+		return nil
+	}
+
+	for _, name := range names {
+
+		_, paths, _, _, err := p.findIncludes(name, branch)
+
+		if err != nil {
+			continue
+		}
+
+		for _, path := range paths {
+
+			if scanned[path] {
+				continue
+			}
+
+			scanned[path] = true
+
+			lines, _, err := p.scanFile(path)
+
+			if err != nil {
+				continue
+			}
+
+			if err := p.hoistParams(lines, scanned, missing); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseParamDirective handles an @param that wasn't declared before its file
+// was compiled, such as one entered in a Session
+func (p *parser) parseParamDirective(branch *scannerLine, tokens []token, scope *scope) error {
+
+	if scope != p.rootScope {
+		return p.err(branch, "@param must be at the top level of a file")
+	}
+
+	param, err := p.declareParam(branch, tokens)
+
+	if err != nil {
+		return err
+	}
+
+	if param.Required && !param.Supplied {
+		return p.err(branch, "Missing required param(s): $%s", param.Name)
+	}
+
+	return nil
+}
+
+// declareParam declares the param of an @param line. A line that's already
+// been declared, because it was hoisted or its file is included again, isn't
+// declared twice.
+func (p *parser) declareParam(branch *scannerLine, tokens []token) (param Param, err error) {
+
+	for _, declared := range p.params {
+		if declared.Reference == branch.String() {
+			return declared, nil
+		}
+	}
+
+	arguments := ""
+
+	if len(tokens) > 1 {
+		arguments = tokens[1].content
+	}
+
+	matches := paramDeclarationMatcher.FindStringSubmatch(arguments)
+
+	if matches == nil {
+		return param, p.err(branch, `@param needs a variable, such as @param $name "description"`)
+	}
+
+	t, err := parseParamType(matches[2])
+
+	if matches[2] != "" && err != nil {
+		return param, p.err(branch, "@param $%s: %s", matches[1], err.Error())
+	}
+
+	param = Param{
+		Name:      matches[1],
+		File:      branch.file,
+		Line:      branch.line,
+		Reference: branch.String(),
+		Type:      matches[2],
+		Allowed:   t.allowed(),
+		Default:   matches[3],
+		Required:  matches[3] == "",
+		Docs:      unquote(matches[4]),
+	}
+
+	if v := p.rootScope.declaredVariable(param.Name); v != nil && v.value != "" {
+		param.Supplied = true
+		param.Value = v.value
+	} else if !param.Required {
+		p.rootScope.setVariable(param.Name, param.Default)
+		param.Value = param.Default
+	}
+
+	p.params = append(p.params, param)
+
+	if err := t.check(param.Value); err != nil {
+		return param, p.err(branch, "$%s %s", param.Name, err.Error())
+	}
+
+	return param, nil
+}
@@ -0,0 +1,211 @@
+package scl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserCanDeclareParams(t *testing.T) {
+
+	for cycle, test := range []struct {
+		source string
+		params map[string]string
+		err    string
+		hcl    string
+		result Params
+	}{
+		{
+			source: `@param $region "The AWS region"
+@param $environment: "dev"|"prod" = "dev" "The environment"
+
+deploy
+    region = $region
+    environment = $environment`,
+			params: map[string]string{"region": `"eu-west-1"`},
+			hcl: `deploy {
+  region = "eu-west-1"
+  environment = "dev"
+}`,
+			result: Params{
+				{
+					Name:      "region",
+					File:      "main.scl",
+					Line:      1,
+					Reference: "main.scl:1",
+					Docs:      "The AWS region",
+					Required:  true,
+					Supplied:  true,
+					Value:     `"eu-west-1"`,
+				},
+				{
+					Name:      "environment",
+					File:      "main.scl",
+					Line:      2,
+					Reference: "main.scl:2",
+					Docs:      "The environment",
+					Default:   `"dev"`,
+					Type:      `"dev"|"prod"`,
+					Allowed:   []string{`"dev"`, `"prod"`},
+					Value:     `"dev"`,
+				},
+			},
+		},
+		{
+			source: `@param $size = 3
+
+deploy
+    size = $size`,
+			params: map[string]string{"size": "5"},
+			hcl: `deploy {
+  size = 5
+}`,
+			result: Params{
+				{Name: "size", File: "main.scl", Line: 1, Reference: "main.scl:1", Default: "3", Supplied: true, Value: "5"},
+			},
+		},
+		{
+			source: `@mixin()
+    value = $missing
+
+mixin()
+
+@param $region "The AWS region"
+@param $zone
+@param $size = 3`,
+			err: "[main.scl:6] Missing required param(s): $region, $zone",
+		},
+		{
+			source: `@param $environment: "dev"|"prod"`,
+			params: map[string]string{"environment": `"staging"`},
+			err:    `[main.scl:1] $environment must be one of "dev", "prod", not "staging"`,
+		},
+		{
+			source: `@param $replicas: number = 2
+@param $debug: bool
+
+deploy
+    replicas = $replicas`,
+			params: map[string]string{"replicas": `"3"`, "debug": "maybe"},
+			err:    "[main.scl:2] $debug must be true or false, not maybe",
+		},
+		{
+			source: `@param $replicas: number = 2 "How many to run"
+
+deploy
+    replicas = $replicas`,
+			params: map[string]string{"replicas": `"3"`},
+			hcl: `deploy {
+  replicas = "3"
+}`,
+			result: Params{
+				{Name: "replicas", File: "main.scl", Line: 1, Reference: "main.scl:1", Docs: "How many to run", Default: "2", Type: "number", Supplied: true, Value: `"3"`},
+			},
+		},
+		{
+			source: `@param $replicas: number
+@param $debug: bool = false
+
+@deploy($count: number, $verbose: bool)
+    deploy
+        replicas = $count
+        debug = $verbose
+
+deploy($replicas, $debug)`,
+			params: map[string]string{"replicas": `"3"`, "debug": `"true"`},
+			hcl: `deploy {
+  replicas = "3"
+  debug = "true"
+}`,
+			result: Params{
+				{Name: "replicas", File: "main.scl", Line: 1, Reference: "main.scl:1", Type: "number", Required: true, Supplied: true, Value: `"3"`},
+				{Name: "debug", File: "main.scl", Line: 2, Reference: "main.scl:2", Default: "false", Type: "bool", Supplied: true, Value: `"true"`},
+			},
+		},
+		{
+			source: `@param region`,
+			err:    `[main.scl:1] @param needs a variable, such as @param $name "description"`,
+		},
+		{
+			source: "block\n    @param $region",
+			err:    "[main.scl:2] @param must be at the top level of a file",
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		fs := newMemoryFileSystem()
+		fs.set("main.scl", test.source, time.Now())
+
+		p, err := NewParser(fs)
+		require.NoError(t, err)
+
+		for name, value := range test.params {
+			p.SetParam(name, value)
+		}
+
+		err = p.Parse("main.scl")
+
+		if test.err != "" {
+			require.Error(t, err)
+			require.Equal(t, test.err, err.Error())
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, test.hcl, p.String())
+		require.Equal(t, test.result, p.Params())
+	}
+}
+
+func Test_MissingParamsCanBeListed(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "@param $a \"First\"\n@param $b = 1\n@param $c", time.Now())
+
+	p, err := NewParser(fs)
+	require.NoError(t, err)
+	require.Error(t, p.Parse("main.scl"))
+
+	missing := p.Params().Missing()
+
+	require.Len(t, missing, 2)
+	require.Equal(t, "$a (First)", missing[0].String())
+	require.Equal(t, "$c", missing[1].String())
+}
+
+func Test_MissingParamsAreReportedAcrossIncludes(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "include(\"a.scl\")\ninclude(\"b.scl\")", time.Now())
+	fs.set("a.scl", "@param $a\n\na = $a", time.Now())
+	fs.set("b.scl", "@param $b \"Second\"\n\nb = $b", time.Now())
+
+	p, err := NewParser(fs)
+	require.NoError(t, err)
+
+	err = p.Parse("main.scl")
+	require.Error(t, err)
+	require.Equal(t, "[a.scl:1] Missing required param(s): $a, $b", err.Error())
+
+	missing := p.Params().Missing()
+
+	require.Len(t, missing, 2)
+	require.Equal(t, "$a", missing[0].String())
+	require.Equal(t, "$b (Second)", missing[1].String())
+}
+
+func Test_ParamsAreDeclaredOnceWhenTheirFileIsIncludedTwice(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "include(\"lib.scl\")\ninclude(\"lib.scl\")", time.Now())
+	fs.set("lib.scl", "@param $r = 1\nr = $r", time.Now())
+
+	p, err := NewParser(fs)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse("main.scl"))
+
+	require.Equal(t, Params{
+		{Name: "r", File: "lib.scl", Line: 1, Reference: "lib.scl:1", Default: "1", Value: "1"},
+	}, p.Params())
+}
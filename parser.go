@@ -2,11 +2,15 @@ package scl
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
 	hclparser "github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/v2"
 )
 
 const (
@@ -33,16 +37,82 @@ the next file will be able to access it. This can become confusing quickly,
 so it's usually best to parse only one file and let it explicitly include
 and other files at the SCL level.
 
+The compiled output is available as HCL from String(), as JSON from JSON() and
+as YAML from YAML(). JSON is produced by the HCL decoder, so it has the same
+shape as the output decoded into an interface{}: blocks become objects in
+lists, nested once for each block label, and repeated blocks add more objects
+to the list. YAML is written to read naturally instead: a block becomes a
+sequence of mappings, with one mapping for each time the block appears, and
+its labels become keys of the mappings it's nested in; an object assigned to
+a key, as in `metadata = { name = "web" }`, becomes a single mapping; lists
+become sequences; and multi-line strings such as heredocs become literal
+block scalars. A block's shape never depends on how many times it's repeated.
+Keys keep the order of the HCL output.
+
+The output is also built as an HCL syntax tree as it's compiled, which is
+returned by AST(). Programs that post-process the output can walk the tree
+instead of parsing the HCL text. Positions in the tree refer to the SCL
+source that produced each node rather than to the HCL text; where variables
+have been interpolated into a line, columns are approximate.
+
+Comments in the SCL are dropped by default, apart from HCL # comments. With
+SetCommentMode(), // comments and docblocks are written to the output
+too, at the indentation of the lines that follow them. Comments directly
+before a mixin declaration are its documentation, so they're never written.
+
+The layout of the HCL output, such as its indentation, can be changed with
+SetOutputFormat().
+
+HCL2() writes the output in HCL2 native syntax, as used by Terraform 0.12 and
+later, and can optionally check the result with the HCL2 parser. Errors in
+HCL2 output are reported at the SCL source that produced them. HCL2Body()
+gives the same output as an hcl.Body, for decoding with gohcl or with a
+schema; every range and diagnostic it produces refers to the SCL source.
+
 SCL is an auto-documenting language, and the documentation is obtained using
 the Parser's Documentation() function. Only mixins are currently documented.
 Unlike the String() function, the documentation returned for Documentation()
 only includes the nominated file.
+
+Every file read while parsing, including includes, is recorded along with its
+last modification time. Stale() reports whether any of those files has since
+changed, or a file that couldn't be read now can be, which tells a
+long-running program when its compiled output needs to be regenerated. Given
+a Cache, the Parser will also avoid re-reading files that haven't changed
+since they were last scanned. The full include graph, including
+the include path or vendor directory that satisfied each include, is returned
+by Dependencies().
+
+SCL can report problems itself with directives. @error "message" stops the
+compilation with an error at its line, @warn "message" records a warning that
+is returned by Warnings(), and @debug writes a value to the writer given to
+SetDebugWriter(), which is stderr by default. Variables in each message are
+interpolated.
+
+A file can declare the params it expects with @param directives, which are
+returned by Params() along with whether each was given. They're checked
+before the rest of the file is compiled, along with those of the files it
+includes by name, and if any required param is missing Parse() fails with an
+error that names all of them.
 */
 type Parser interface {
 	Parse(fileName string) error
 	Documentation(fileName string) (MixinDocs, error)
 	SetParam(name, value string)
+	SetCommentMode(mode CommentMode)
+	SetOutputFormat(format OutputFormat)
 	AddIncludePath(name string)
+	SetCache(cache *Cache)
+	Stale() (bool, error)
+	Dependencies() Dependencies
+	JSON() ([]byte, error)
+	YAML() ([]byte, error)
+	AST() *ast.File
+	HCL2(validate bool) ([]byte, error)
+	HCL2Body() (hcl.Body, error)
+	Warnings() Warnings
+	Params() Params
+	SetDebugWriter(w io.Writer)
 	String() string
 }
 
@@ -52,6 +122,22 @@ type parser struct {
 	output       []string
 	indent       int
 	includePaths []string
+	cache        *Cache
+	files        map[string]time.Time
+	unreadable   map[string]bool
+	dependencies Dependencies
+	dependency   *Dependency
+	ast          *ast.File
+	lists        []*ast.ObjectList
+	commentMode  CommentMode
+	format       OutputFormat
+	lint         *lintRecorder
+	symbols      *symbolRecorder
+	trace        *traceRecorder
+	tests        *testRecorder
+	warnings     Warnings
+	params       Params
+	debug        io.Writer
 }
 
 /*
@@ -61,9 +147,16 @@ includes using the FileSystem provided.
 */
 func NewParser(fs FileSystem) (Parser, error) {
 
+	root := &ast.ObjectList{}
+
 	p := &parser{
-		fs:        fs,
-		rootScope: newScope(),
+		fs:         fs,
+		rootScope:  newScope(),
+		files:      make(map[string]time.Time),
+		unreadable: make(map[string]bool),
+		ast:        &ast.File{Node: root},
+		lists:      []*ast.ObjectList{root},
+		debug:      os.Stderr,
 	}
 
 	return p, nil
@@ -77,18 +170,94 @@ func (p *parser) AddIncludePath(name string) {
 	p.includePaths = append(p.includePaths, name)
 }
 
+func (p *parser) SetCache(cache *Cache) {
+	p.cache = cache
+}
+
+func (p *parser) Stale() (bool, error) {
+
+	for fileName, lastModified := range p.files {
+
+		if lastModified.IsZero() {
+			return true, nil
+		}
+
+		f, modified, err := p.fs.ReadCloser(fileName)
+
+		if err != nil {
+			// A file that can no longer be read has certainly changed
+			return true, nil
+		}
+
+		f.Close()
+
+		if !modified.Equal(lastModified) {
+			return true, nil
+		}
+	}
+
+	// A file that couldn't be read has changed once it can be
+	for fileName := range p.unreadable {
+
+		if f, _, err := p.fs.ReadCloser(fileName); err == nil {
+			f.Close()
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (p *parser) Dependencies() Dependencies {
+	return p.dependencies
+}
+
+func (p *parser) AST() *ast.File {
+	return p.ast
+}
+
 func (p *parser) String() string {
-	return strings.Join(p.output, "\n")
+	return strings.Join(p.formatOutput(), "\n")
 }
 
 func (p *parser) Parse(fileName string) error {
+	return p.parseDependency(Dependency{File: fileName})
+}
+
+func (p *parser) parseDependency(dep Dependency) error {
+
+	parent := p.dependency
+	p.dependency = &dep
+
+	err := p.parseFile(dep.File)
+
+	p.dependency = parent
+
+	// Files are recorded even if they fail to parse, so that callers can
+	// tell which files need to change to fix the error
+	if parent == nil {
+		p.dependencies = append(p.dependencies, dep)
+	} else {
+		parent.Children = append(parent.Children, dep)
+	}
+
+	return err
+}
 
-	lines, err := p.scanFile(fileName)
+func (p *parser) parseFile(fileName string) error {
+
+	lines, lastModified, err := p.scanFile(fileName)
 
 	if err != nil {
 		return err
 	}
 
+	p.files[fileName] = lastModified
+
+	if err := p.declareParams(lines); err != nil {
+		return err
+	}
+
 	if err := p.parseTree(lines, newTokeniser(), p.rootScope); err != nil {
 		return err
 	}
@@ -100,7 +269,7 @@ func (p *parser) Documentation(fileName string) (MixinDocs, error) {
 
 	docs := MixinDocs{}
 
-	lines, err := p.scanFile(fileName)
+	lines, _, err := p.scanFile(fileName)
 
 	if err != nil {
 		return docs, err
@@ -113,43 +282,54 @@ func (p *parser) Documentation(fileName string) (MixinDocs, error) {
 	return docs, nil
 }
 
-func (p *parser) scanFile(fileName string) (lines scannerTree, err error) {
+func (p *parser) scanFile(fileName string) (lines scannerTree, lastModified time.Time, err error) {
 
-	f, _, err := p.fs.ReadCloser(fileName)
+	f, lastModified, err := p.fs.ReadCloser(fileName)
 
 	if err != nil {
-		return lines, fmt.Errorf("Can't read %s: %s", fileName, err)
+		p.unreadable[fileName] = true
+		return lines, lastModified, fmt.Errorf("Can't read %s: %s", fileName, err)
 	}
 
 	defer f.Close()
 
+	if p.cache != nil {
+		if cached, ok := p.cache.lines(fileName, lastModified); ok {
+			return cached, lastModified, nil
+		}
+	}
+
 	lines, err = newScanner(f, fileName).scan()
 
 	if err != nil {
-		return lines, fmt.Errorf("Can't scan %s: %s", fileName, err)
+		return lines, lastModified, fmt.Errorf("Can't scan %s: %s", fileName, err)
+	}
+
+	if p.cache != nil {
+		p.cache.setLines(fileName, lastModified, lines)
 	}
 
 	return
 }
 
-func (p *parser) isValid(hclString string) error {
+func (p *parser) parseHCL(hclString string) (*ast.File, error) {
 
-	e := hcl.Decode(&struct{}{}, hclString)
+	f, e := hclparser.Parse([]byte(hclString))
 
 	if pe, ok := e.(*hclparser.PosError); ok {
-		return pe.Err
-	} else if pe != nil {
-		return pe
+		return nil, pe.Err
+	} else if e != nil {
+		return nil, e
 	}
 
-	return nil
+	return f, nil
 }
 
 func (p *parser) indentedValue(literal string) string {
-	return fmt.Sprintf("%s%s", strings.Repeat(" ", p.indent*hclIndentSize), literal)
+	return fmt.Sprintf("%s%s", p.indentation(p.indent), literal)
 }
 
-func (p *parser) writeLiteralToOutput(scope *scope, literal string, block bool) error {
+func (p *parser) writeLiteralToOutput(branch *scannerLine, scope *scope, literal string, block bool) error {
 
 	literal, err := scope.interpolateLiteral(literal)
 
@@ -161,7 +341,13 @@ func (p *parser) writeLiteralToOutput(scope *scope, literal string, block bool)
 
 	if block {
 
-		if err := p.isValid(line + "{}"); err != nil {
+		f, err := p.parseHCL(line + "{}")
+
+		if err != nil {
+			return err
+		}
+
+		if err := p.startNodeBlock(branch, f); err != nil {
 			return err
 		}
 
@@ -171,11 +357,18 @@ func (p *parser) writeLiteralToOutput(scope *scope, literal string, block bool)
 	} else {
 
 		if hashCommentMatcher.MatchString(line) {
-			// Comments are passed through directly
-		} else if err := p.isValid(line + "{}"); err == nil {
+			// Comments are passed through directly, and only added to the
+			// tree if they can be parsed
+			if f, err := p.parseHCL(line); err == nil {
+				p.addNodes(branch, f)
+			}
+		} else if f, err := p.parseHCL(line + "{}"); err == nil {
 			line = line + "{}"
-		} else if err := p.isValid(line); err != nil {
+			p.addNodes(branch, f)
+		} else if f, err := p.parseHCL(line); err != nil {
 			return err
+		} else {
+			p.addNodes(branch, f)
 		}
 	}
 
@@ -187,6 +380,7 @@ func (p *parser) writeLiteralToOutput(scope *scope, literal string, block bool)
 func (p *parser) endBlock() {
 	p.indent--
 	p.output = append(p.output, p.indentedValue("}"))
+	p.endNodeBlock()
 }
 
 func (p *parser) err(branch *scannerLine, e string, args ...interface{}) error {
@@ -195,6 +389,8 @@ func (p *parser) err(branch *scannerLine, e string, args ...interface{}) error {
 
 func (p *parser) parseTree(tree scannerTree, tkn *tokeniser, scope *scope) error {
 
+	var comments []pendingComment
+
 	for _, branch := range tree {
 
 		tokens, err := tkn.tokenise(branch)
@@ -207,6 +403,18 @@ func (p *parser) parseTree(tree scannerTree, tkn *tokeniser, scope *scope) error
 
 			token := tokens[0]
 
+			switch token.kind {
+			case tokenLineComment, tokenCommentStart, tokenCommentEnd, tokenMixinDeclaration, tokenDirective:
+				// Comments wait for the next line, and are dropped if that's a
+				// mixin declaration or a directive, since they're its
+				// documentation
+
+			default:
+				p.writeComments(&comments)
+			}
+
+			p.symbols.visit(branch, tkn, tokens, scope)
+
 			switch token.kind {
 
 			case tokenLiteral:
@@ -223,7 +431,10 @@ func (p *parser) parseTree(tree scannerTree, tkn *tokeniser, scope *scope) error
 					return err
 				}
 
-				scope.setVariable(token.content, value)
+				if v, declared := scope.setVariable(token.content, value); declared {
+					p.lint.declareVariable(branch, v, nil)
+					p.symbols.declareVariable(branch, v)
+				}
 
 			case tokenVariableDeclaration:
 
@@ -233,7 +444,10 @@ func (p *parser) parseTree(tree scannerTree, tkn *tokeniser, scope *scope) error
 					return err
 				}
 
-				scope.setArgumentVariable(token.content, value)
+				shadowed := scope.declaredVariable(token.content)
+				v := scope.setArgumentVariable(token.content, value)
+				p.lint.declareVariable(branch, v, shadowed)
+				p.symbols.declareVariable(branch, v)
 
 			case tokenConditionalVariableAssignment:
 
@@ -243,11 +457,15 @@ func (p *parser) parseTree(tree scannerTree, tkn *tokeniser, scope *scope) error
 					return err
 				}
 
-				if v := scope.variable(token.content); v == "" {
-					scope.setArgumentVariable(token.content, value)
+				if v := scope.declaredVariable(token.content); v == nil || v.value == "" {
+					v = scope.setArgumentVariable(token.content, value)
+					p.lint.declareVariable(branch, v, nil)
+					p.symbols.declareVariable(branch, v)
 				}
 
 			case tokenMixinDeclaration:
+				comments = nil
+
 				if err := p.parseMixinDeclaration(branch, tokens, scope); err != nil {
 					return err
 				}
@@ -257,7 +475,24 @@ func (p *parser) parseTree(tree scannerTree, tkn *tokeniser, scope *scope) error
 					return err
 				}
 
-			case tokenCommentStart, tokenCommentEnd, tokenLineComment:
+			case tokenDirective:
+				comments = nil
+
+				if err := p.parseDirective(branch, tkn, tokens, scope); err != nil {
+					return err
+				}
+
+			case tokenLineComment:
+				if p.keepComment(scope) {
+					comments = append(comments, pendingComment{branch, lineComment(branch)})
+				}
+
+			case tokenCommentStart:
+				if p.keepComment(scope) {
+					comments = append(comments, pendingComment{branch, p.docblockComment(branch)})
+				}
+
+			case tokenCommentEnd:
 				// Do nothing
 
 			default:
@@ -266,6 +501,8 @@ func (p *parser) parseTree(tree scannerTree, tkn *tokeniser, scope *scope) error
 		}
 	}
 
+	p.writeComments(&comments)
+
 	return nil
 }
 
@@ -296,6 +533,10 @@ func (p *parser) parseTreeForDocumentation(tree scannerTree, tkn *tokeniser, doc
 			case tokenCommentStart:
 				p.parseBlockComment(branch.children, &comments, branch.line, 0)
 
+			case tokenDirective:
+				// Directives, and the blocks under them, aren't documented
+				resetComments()
+
 			case tokenMixinDeclaration:
 
 				if token.content[0] == '_' {
@@ -310,6 +551,7 @@ func (p *parser) parseTreeForDocumentation(tree scannerTree, tkn *tokeniser, doc
 					Reference: branch.String(),
 					Signature: string(branch.content),
 					Docs:      strings.Join(comments, "\n"),
+					Params:    mixinDocParams(tokens[1:]),
 				}
 
 				// Clear comments
@@ -334,6 +576,34 @@ func (p *parser) parseTreeForDocumentation(tree scannerTree, tkn *tokeniser, doc
 	return nil
 }
 
+// mixinDocParams describes the parameters in a mixin's signature
+func mixinDocParams(tokens []token) (params MixinParams) {
+
+	for _, t := range tokens {
+
+		switch t.kind {
+
+		case tokenVariable:
+			params = append(params, MixinParam{Name: t.content, Required: true})
+
+		case tokenVariableAssignment:
+			params = append(params, MixinParam{Name: t.content})
+
+		case tokenLiteral:
+			if l := len(params); l > 0 {
+				params[l-1].Default = t.content
+			}
+
+		case tokenParamType:
+			if l := len(params); l > 0 {
+				params[l-1].Type = t.content
+			}
+		}
+	}
+
+	return
+}
+
 func (p *parser) parseBlockComment(tree scannerTree, comments *[]string, line, indentation int) error {
 
 	for _, branch := range tree {
@@ -362,7 +632,7 @@ func (p *parser) parseLiteral(branch *scannerLine, tkn *tokeniser, token token,
 
 	children := len(branch.children) > 0
 
-	if err := p.writeLiteralToOutput(scope, token.content, children); err != nil {
+	if err := p.writeLiteralToOutput(branch, scope, token.content, children); err != nil {
 		return p.err(branch, err.Error())
 	}
 
@@ -387,6 +657,7 @@ func (p *parser) parseMixinDeclaration(branch *scannerLine, tokens []token, scop
 	var (
 		arguments []token
 		defaults  []string
+		types     []paramType
 		current   token
 	)
 
@@ -410,6 +681,7 @@ func (p *parser) parseMixinDeclaration(branch *scannerLine, tokens []token, scop
 
 			arguments = append(arguments, current)
 			defaults = append(defaults, value)
+			types = append(types, "")
 			literalExpected = false
 
 		case tokenVariableAssignment:
@@ -430,8 +702,28 @@ func (p *parser) parseMixinDeclaration(branch *scannerLine, tokens []token, scop
 
 			arguments = append(arguments, v)
 			defaults = append(defaults, "")
+			types = append(types, "")
 			i++
 
+		case tokenParamType:
+
+			if len(arguments) == 0 || literalExpected {
+				return p.err(branch, "Argument declaration %d: Unexpected type %s", i, v.content)
+			}
+
+			last := len(arguments) - 1
+			t, err := parseParamType(v.content)
+
+			if err != nil {
+				return p.err(branch, "Argument declaration %d [%s]: %s", i, arguments[last].content, err.Error())
+			}
+
+			if err := t.check(defaults[last]); err != nil {
+				return p.err(branch, "Argument declaration %d [%s]: The default %s", i, arguments[last].content, err.Error())
+			}
+
+			types[last] = t
+
 		default:
 			return p.err(branch, "Argument declaration %d [%s] is not a variable or a variable assignment", i, v.content)
 		}
@@ -445,7 +737,9 @@ func (p *parser) parseMixinDeclaration(branch *scannerLine, tokens []token, scop
 		return p.err(branch, "Expected eqaual numbers of arguments and defaults (a:%d,d:%d)", a, d)
 	}
 
-	scope.setMixin(tokens[0].content, branch, arguments, defaults)
+	p.lint.declareMixin(branch, tokens[0].content, scope)
+	scope.setMixin(tokens[0].content, branch, arguments, defaults, types)
+	p.trace.declareMixin(scope.mixins[tokens[0].content])
 
 	return nil
 }
@@ -482,17 +776,35 @@ func (p *parser) parseFunctionCall(branch *scannerLine, tkn *tokeniser, tokens [
 		return p.err(branch, "Wrong number of arguments for %s (required %d, got %d)", tokens[0].content, r, g)
 	}
 
+	// Check the argument types
+	for i, t := range mx.types {
+		if err := t.check(args[i]); err != nil {
+			return p.err(branch, "Argument $%s of %s %s", mx.arguments[i].name, tokens[0].content, err.Error())
+		}
+	}
+
+	p.lint.callMixin(branch, tokens[0].content, mx)
+
 	// Set the argument values
 	for i := 0; i < len(mx.arguments); i++ {
-		scope.setArgumentVariable(mx.arguments[i].name, args[i])
+		v := scope.setArgumentVariable(mx.arguments[i].name, args[i])
+		p.lint.declareParam(mx, tokens[0].content, mx.arguments[i].name, v)
+		p.symbols.declareParam(mx, mx.arguments[i].name, v)
 	}
 
 	// Set an anchor branch for the __body__ built-in
 	scope.branch = branch
 	scope.branchScope = scope.parent
+	scope.inMixin = true
+
+	p.trace.startCall(branch, tokens[0].content, mx.declaration, mx, args, len(p.output))
 
 	// Call the function!
-	return p.parseTree(mx.declaration.children, tkn, scope)
+	err = p.parseTree(mx.declaration.children, tkn, scope)
+
+	p.trace.endCall(len(p.output))
+
+	return err
 }
 
 func (p *parser) parseBodyCall(branch *scannerLine, tkn *tokeniser, scope *scope) error {
@@ -505,57 +817,79 @@ func (p *parser) parseBodyCall(branch *scannerLine, tkn *tokeniser, scope *scope
 		return p.err(branch, "Unexpected error: No anchor branch!")
 	}
 
+	p.lint.callBody(branch, len(scope.branch.children) > 0)
+
 	s := scope.branchScope.clone()
 	s.mixins = scope.mixins
 	s.variables = scope.variables // FIXME Merge?
 
-	return p.parseTree(scope.branch.children, tkn, s)
-}
+	p.trace.startCall(branch, builtinMixinBody, scope.branch, nil, nil, len(p.output))
 
-func (p *parser) includeGlob(name string, branch *scannerLine) error {
+	err := p.parseTree(scope.branch.children, tkn, s)
 
-	name = strings.TrimSuffix(strings.Trim(name, `"'`), ".scl") + ".scl"
+	p.trace.endCall(len(p.output))
 
-	vendorPath := []string{filepath.Join(filepath.Dir(branch.file), "vendor")}
-	vendorPath = append(vendorPath, p.includePaths...)
+	return err
+}
+
+func (p *parser) includeGlob(name string, branch *scannerLine) error {
 
-	var paths []string
+	name, paths, includePath, vendored, err := p.findIncludes(name, branch)
 
-	for _, ip := range vendorPath {
+	if err != nil {
+		return err
+	}
 
-		ipaths, err := p.fs.Glob(ip + "/" + name)
+	for _, path := range paths {
 
-		if err != nil {
-			return err
+		dep := Dependency{
+			File:        path,
+			Include:     name,
+			Reference:   branch.String(),
+			IncludePath: includePath,
+			Vendored:    vendored,
 		}
 
-		if len(ipaths) > 0 {
-			paths = ipaths
-			break
+		if err := p.parseDependency(dep); err != nil {
+			return fmt.Errorf(err.Error())
 		}
 	}
 
-	if len(paths) == 0 {
+	return nil
+}
+
+// findIncludes finds the files an include names, looking in the vendor
+// directory beside the including file and then the include paths before the
+// working directory
+func (p *parser) findIncludes(name string, branch *scannerLine) (pattern string, paths []string, includePath string, vendored bool, err error) {
 
-		var err error
-		paths, err = p.fs.Glob(name)
+	pattern = strings.TrimSuffix(strings.Trim(name, `"'`), ".scl") + ".scl"
+
+	vendorPath := []string{filepath.Join(filepath.Dir(branch.file), "vendor")}
+	vendorPath = append(vendorPath, p.includePaths...)
+
+	for i, ip := range vendorPath {
+
+		ipaths, err := p.fs.Glob(ip + "/" + pattern)
 
 		if err != nil {
-			return err
+			return pattern, nil, "", false, err
+		}
+
+		if len(ipaths) > 0 {
+			return pattern, ipaths, ip, i == 0, nil
 		}
 	}
 
-	if len(paths) == 0 {
-		return fmt.Errorf("Can't read %s: no files found", name)
+	if paths, err = p.fs.Glob(pattern); err != nil {
+		return pattern, nil, "", false, err
 	}
 
-	for _, path := range paths {
-		if err := p.Parse(path); err != nil {
-			return fmt.Errorf(err.Error())
-		}
+	if len(paths) == 0 {
+		return pattern, nil, "", false, fmt.Errorf("Can't read %s: no files found", pattern)
 	}
 
-	return nil
+	return pattern, paths, "", false, nil
 }
 
 func (p *parser) parseIncludeCall(branch *scannerLine, tokens []token, scope *scope) error {
@@ -185,6 +185,32 @@ var2 = 3`,
 			fileName: "fixtures/valid/docblock.scl",
 			hcl:      ``,
 		},
+		{
+			fileName: "fixtures/valid/repeated-blocks.scl",
+			hcl: `service "web" {
+  port = 80
+  listener {
+    protocol = "http"
+  }
+  listener {
+    protocol = "https"
+  }
+}
+service "api" {
+  port = 8080
+}
+service "web" {
+  port = 81
+  tags = ["a", "b"]
+}`,
+		},
+		{
+			fileName: "fixtures/valid/preserved-comments.scl",
+			hcl: `block "a" {
+  value = 1
+  other = 2
+}`,
+		},
 		{
 			fileName: "fixtures/valid/vendor.scl",
 			hcl:      `this = "included from vendor"`,
@@ -285,6 +311,7 @@ func Test_AParserCanExtractACommentTree(t *testing.T) {
 					Reference: "fixtures/valid/docblock.scl:20",
 					Signature: "@inside0($var)",
 					Docs:      "part 1\npart 2",
+					Params:    MixinParams{{Name: "var", Required: true}},
 				},
 				MixinDoc{
 					Name:      "inside1",
@@ -292,6 +319,7 @@ func Test_AParserCanExtractACommentTree(t *testing.T) {
 					Line:      23,
 					Reference: "fixtures/valid/docblock.scl:23",
 					Signature: "@inside1($var, $var2)",
+					Params:    MixinParams{{Name: "var", Required: true}, {Name: "var2", Required: true}},
 				},
 			},
 		},
@@ -301,6 +329,7 @@ func Test_AParserCanExtractACommentTree(t *testing.T) {
 			Line:      30,
 			Reference: "fixtures/valid/docblock.scl:30",
 			Signature: "@mixin2($var)",
+			Params:    MixinParams{{Name: "var", Required: true}},
 			Children: MixinDocs{
 				MixinDoc{
 					Name:      "inside0",
@@ -309,6 +338,7 @@ func Test_AParserCanExtractACommentTree(t *testing.T) {
 					Reference: "fixtures/valid/docblock.scl:36",
 					Signature: "@inside0($var)",
 					Docs:      "This is a mixin inside mixin2",
+					Params:    MixinParams{{Name: "var", Required: true}},
 				},
 			},
 		},
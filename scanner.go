@@ -53,7 +53,7 @@ func (s *scanner) scan() (lines scannerTree, err error) {
 
 			if strings.TrimSpace(scanner.Text()) == heredoc {
 				// HCL requires heredocs to be terminated with a newline
-				rawLines = append(rawLines, newLine(s.file, lineNumber, 0, heredocContent+"\n"))
+				rawLines = append(rawLines, newLine(s.file, heredocLine, 0, heredocContent+"\n"))
 				heredoc = ""
 				heredocContent = ""
 			}
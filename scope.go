@@ -8,18 +8,21 @@ import (
 type variable struct {
 	name  string
 	value string
+	uses  int
 }
 
 type mixin struct {
 	declaration *scannerLine
 	arguments   []variable
 	defaults    []string
+	types       []paramType
 }
 
 type scope struct {
 	parent      *scope
 	branch      *scannerLine
 	branchScope *scope
+	inMixin     bool
 	variables   map[string]*variable
 	mixins      map[string]*mixin
 }
@@ -31,19 +34,26 @@ func newScope() *scope {
 	}
 }
 
-func (s *scope) setArgumentVariable(name, value string) {
-	s.variables[name] = &variable{name, value}
+func (s *scope) setArgumentVariable(name, value string) *variable {
+	v := &variable{name: name, value: value}
+	s.variables[name] = v
+	return v
 }
 
-func (s *scope) setVariable(name, value string) {
+// setVariable sets a variable, declaring it if it doesn't already exist
+func (s *scope) setVariable(name, value string) (v *variable, declared bool) {
 
 	v, ok := s.variables[name]
 
 	if !ok || v == nil {
-		s.variables[name] = &variable{name, value}
-	} else {
-		s.variables[name].value = value
+		v = &variable{name: name, value: value}
+		s.variables[name] = v
+		return v, true
 	}
+
+	v.value = value
+
+	return v, false
 }
 
 func (s *scope) variable(name string) string {
@@ -54,14 +64,22 @@ func (s *scope) variable(name string) string {
 		return ""
 	}
 
+	s.variables[name].uses++
+
 	return s.variables[name].value
 }
 
-func (s *scope) setMixin(name string, declaration *scannerLine, argumentTokens []token, defaults []string) {
+// declaredVariable returns a variable without counting it as used
+func (s *scope) declaredVariable(name string) *variable {
+	return s.variables[name]
+}
+
+func (s *scope) setMixin(name string, declaration *scannerLine, argumentTokens []token, defaults []string, types []paramType) {
 
 	mixin := &mixin{
 		declaration: declaration,
 		defaults:    defaults,
+		types:       types,
 	}
 
 	for _, t := range argumentTokens {
@@ -264,6 +282,7 @@ func (s *scope) clone() *scope {
 	s2.parent = s
 	s2.branch = s.branch
 	s2.branchScope = s.branchScope
+	s2.inMixin = s.inMixin
 
 	for k, v := range s.variables {
 		s2.variables[k] = v
@@ -0,0 +1,165 @@
+package scl
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+A Session compiles SCL a piece at a time, as an interactive tool such as a
+REPL needs to. Every piece is compiled in the same root scope, so the
+variables and mixins declared by one piece are available to the next, and
+includes are resolved as if each piece were a file in the working directory.
+
+Eval() returns only the HCL that a piece produced, while String() returns the
+output of every piece so far. If a piece fails, none of its output is kept,
+but any variables and mixins it declared before the error are. Reset()
+starts again with an empty scope, keeping the params and include paths.
+
+SetEnv() declares a variable in the same way as SetParam(), for values that
+are imported from the environment rather than given explicitly. Variables()
+lists the variables declared by the pieces and the params, but leaves out
+those from the environment unless a piece or a param changes them.
+*/
+type Session interface {
+	Eval(src string) (string, error)
+	SetParam(name, value string)
+	SetEnv(name, value string)
+	AddIncludePath(name string)
+	Variables() map[string]string
+	Mixins() map[string]string
+	Reset()
+	String() string
+}
+
+type session struct {
+	fs           FileSystem
+	params       [][2]string
+	env          [][2]string
+	includePaths []string
+	parser       *parser
+	entries      int
+}
+
+/*
+NewSession creates a Session that reads includes using the FileSystem
+provided, in the same way as a Parser.
+*/
+func NewSession(fs FileSystem) (Session, error) {
+
+	s := &session{fs: fs}
+	s.Reset()
+
+	return s, nil
+}
+
+func (s *session) SetParam(name, value string) {
+	s.params = append(s.params, [2]string{name, value})
+	s.parser.SetParam(name, value)
+}
+
+func (s *session) SetEnv(name, value string) {
+	s.env = append(s.env, [2]string{name, value})
+	s.parser.SetParam(name, value)
+}
+
+func (s *session) AddIncludePath(name string) {
+	s.includePaths = append(s.includePaths, name)
+	s.parser.AddIncludePath(name)
+}
+
+func (s *session) Reset() {
+
+	p, _ := NewParser(s.fs)
+	s.parser = p.(*parser)
+	s.entries = 0
+
+	for _, env := range s.env {
+		s.parser.SetParam(env[0], env[1])
+	}
+
+	for _, param := range s.params {
+		s.parser.SetParam(param[0], param[1])
+	}
+
+	for _, includePath := range s.includePaths {
+		s.parser.AddIncludePath(includePath)
+	}
+}
+
+/*
+Eval compiles a piece of SCL and returns the HCL it produced. Errors refer to
+the piece as <session:N>, where N counts the pieces since the last reset.
+*/
+func (s *session) Eval(src string) (string, error) {
+
+	s.entries++
+
+	p := s.parser
+	name := fmt.Sprintf("<session:%d>", s.entries)
+
+	lines, err := newScanner(strings.NewReader(src), name).scan()
+
+	if err != nil {
+		return "", fmt.Errorf("Can't scan %s: %s", name, err)
+	}
+
+	// Remember where the output was, to undo a piece that fails part way
+	var (
+		output   = len(p.output)
+		items    = len(p.lists[0].Items)
+		comments = len(p.ast.Comments)
+	)
+
+	if err := p.parseTree(lines, newTokeniser(), p.rootScope); err != nil {
+		p.output = p.output[:output]
+		p.lists = p.lists[:1]
+		p.lists[0].Items = p.lists[0].Items[:items]
+		p.ast.Comments = p.ast.Comments[:comments]
+		p.indent = 0
+		return "", err
+	}
+
+	return strings.Join(p.output[output:], "\n"), nil
+}
+
+func (s *session) Variables() map[string]string {
+
+	variables := map[string]string{}
+	env := map[string]string{}
+
+	for _, e := range s.env {
+		env[e[0]] = e[1]
+	}
+
+	for _, param := range s.params {
+		delete(env, param[0])
+	}
+
+	for name, v := range s.parser.rootScope.variables {
+
+		if value, imported := env[name]; imported && value == v.value {
+			continue
+		}
+
+		variables[name] = v.value
+	}
+
+	return variables
+}
+
+// Mixins returns the signature of each mixin in the root scope
+func (s *session) Mixins() map[string]string {
+
+	mixins := map[string]string{}
+
+	for name, mx := range s.parser.rootScope.mixins {
+		mixins[name] = string(mx.declaration.content)
+	}
+
+	return mixins
+}
+
+func (s *session) String() string {
+	return s.parser.String()
+}
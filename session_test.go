@@ -0,0 +1,90 @@
+package scl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ASessionKeepsItsScopeBetweenPieces(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("lib.scl", "@wrap($name)\n    block $name\n        __body__()", time.Now())
+
+	session, err := NewSession(fs)
+	require.NoError(t, err)
+
+	session.SetParam("env", "test")
+
+	for cycle, test := range []struct {
+		src    string
+		output string
+		err    string
+	}{
+		{src: `$greeting = hello`},
+		{src: `include("lib.scl")`},
+		{
+			src:    "wrap(\"a\")\n    value = \"$greeting $env\"",
+			output: "block \"a\" {\n  value = \"hello test\"\n}",
+		},
+		{
+			src: "block\n    value = $missing",
+			err: "[<session:4>:2] Unknown variable '$missing'",
+		},
+		{
+			src:    `other = "$greeting"`,
+			output: `other = "hello"`,
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		output, err := session.Eval(test.src)
+
+		if test.err != "" {
+			require.EqualError(t, err, test.err)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, test.output, output)
+	}
+
+	require.Equal(t, "block \"a\" {\n  value = \"hello test\"\n}\nother = \"hello\"", session.String())
+	require.Equal(t, map[string]string{"env": "test", "greeting": "hello"}, session.Variables())
+	require.Equal(t, map[string]string{"wrap": "@wrap($name)"}, session.Mixins())
+
+	session.Reset()
+
+	require.Equal(t, "", session.String())
+	require.Equal(t, map[string]string{"env": "test"}, session.Variables())
+	require.Empty(t, session.Mixins())
+}
+
+func Test_ASessionDoesntListTheEnvironment(t *testing.T) {
+
+	session, err := NewSession(newMemoryFileSystem())
+	require.NoError(t, err)
+
+	session.SetEnv("SECRET", `"hunter2"`)
+	session.SetEnv("HOME", `"/root"`)
+	session.SetEnv("USER", `"root"`)
+	session.SetParam("USER", `"admin"`)
+
+	output, err := session.Eval("secret = $SECRET")
+	require.NoError(t, err)
+	require.Equal(t, `secret = "hunter2"`, output)
+
+	_, err = session.Eval(`$HOME = "/home/admin"`)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{"HOME": `"/home/admin"`, "USER": `"admin"`}, session.Variables())
+
+	session.Reset()
+
+	require.Equal(t, map[string]string{"USER": `"admin"`}, session.Variables())
+
+	output, err = session.Eval("home = $HOME")
+	require.NoError(t, err)
+	require.Equal(t, `home = "/root"`, output)
+}
@@ -0,0 +1,454 @@
+package scl
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A SymbolKind tells mixins and variables apart
+type SymbolKind int
+
+const (
+	// MixinSymbol is a mixin, declared with @name()
+	MixinSymbol SymbolKind = iota
+
+	// VariableSymbol is a variable or a mixin parameter
+	VariableSymbol
+)
+
+/*
+A Position is a place in SCL source. Lines count from one, and columns count
+bytes from zero. The position of a symbol is that of its name, without the $
+or @ before it.
+*/
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+/*
+A Symbol is a mixin or variable, with the place it's declared and every place
+it's used. The parameters of a mixin are declared in the mixin's signature.
+Signature is the declaring line, and Docs is the documentation of a mixin, as
+returned by Documentation().
+*/
+type Symbol struct {
+	Kind        SymbolKind
+	Name        string
+	Declaration Position
+	Signature   string
+	Docs        string
+	References  []Position
+}
+
+/*
+An Index holds the symbols of a compiled SCL file and every file it includes,
+for tools such as editors to find the declaration and uses of a name.
+*/
+type Index struct {
+	Symbols []*Symbol
+
+	// scopes holds the symbols visible at each compiled line of each file
+	scopes map[string]map[int]map[*Symbol]bool
+}
+
+/*
+At returns the symbol declared or used at a position, or nil if there isn't
+one. A position on the $ or @ before a name counts as being on the name.
+*/
+func (i *Index) At(file string, line, column int) *Symbol {
+
+	at := func(pos Position, name string) bool {
+		return pos.File == file && pos.Line == line && column >= pos.Column-1 && column <= pos.Column+len(name)
+	}
+
+	for _, symbol := range i.Symbols {
+
+		if at(symbol.Declaration, symbol.Name) {
+			return symbol
+		}
+
+		for _, ref := range symbol.References {
+			if at(ref, symbol.Name) {
+				return symbol
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+Visible returns the symbols in scope at a line, sorted by name. Since SCL's
+scoping is dynamic, this is every symbol that was in scope any time the
+nearest compiled line at or before it was compiled.
+*/
+func (i *Index) Visible(file string, line int) []*Symbol {
+
+	nearest := 0
+
+	for l := range i.scopes[file] {
+		if l <= line && l > nearest {
+			nearest = l
+		}
+	}
+
+	symbols := []*Symbol{}
+
+	for symbol := range i.scopes[file][nearest] {
+		symbols = append(symbols, symbol)
+	}
+
+	sort.Slice(symbols, func(a, b int) bool {
+
+		if symbols[a].Name != symbols[b].Name {
+			return symbols[a].Name < symbols[b].Name
+		}
+
+		return positionLess(symbols[a].Declaration, symbols[b].Declaration)
+	})
+
+	return symbols
+}
+
+func positionLess(a, b Position) bool {
+
+	if a.File != b.File {
+		return a.File < b.File
+	}
+
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+
+	return a.Column < b.Column
+}
+
+/*
+An Indexer compiles SCL files to build an Index of their symbols. Like the
+Linter, it watches the compilation rather than reading the source, so a
+variable used inside a mixin refers to whichever declaration was in scope
+where the mixin was called; if it's called from more than one place, the
+variable refers to more than one declaration. The symbols used by @test
+blocks are found by compiling them after the file.
+*/
+type Indexer interface {
+	SetParam(name, value string)
+	AddIncludePath(name string)
+	Index(fileName string) (*Index, error)
+}
+
+type indexer struct {
+	fs           FileSystem
+	params       [][2]string
+	includePaths []string
+}
+
+/*
+NewIndexer creates an Indexer that reads files using the FileSystem provided,
+in the same way as a Parser.
+*/
+func NewIndexer(fs FileSystem) (Indexer, error) {
+	return &indexer{fs: fs}, nil
+}
+
+func (i *indexer) SetParam(name, value string) {
+	i.params = append(i.params, [2]string{name, value})
+}
+
+func (i *indexer) AddIncludePath(name string) {
+	i.includePaths = append(i.includePaths, name)
+}
+
+/*
+Index compiles a file and returns the symbols in it and in every file it
+includes. If the file can't be compiled, the symbols found before the error
+are returned along with it.
+*/
+func (i *indexer) Index(fileName string) (*Index, error) {
+
+	p0, err := NewParser(i.fs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := p0.(*parser)
+	p.symbols = newSymbolRecorder()
+	p.tests = &testRecorder{}
+
+	for _, param := range i.params {
+		p.SetParam(param[0], param[1])
+	}
+
+	for _, includePath := range i.includePaths {
+		p.AddIncludePath(includePath)
+	}
+
+	if err = p.Parse(fileName); err == nil {
+		p.tests.run(p)
+	}
+
+	// Mixins are documented by the comments before them
+	docs := map[string]string{}
+
+	var walk func(MixinDocs)
+
+	walk = func(mixinDocs MixinDocs) {
+		for _, doc := range mixinDocs {
+			docs[doc.Reference] = doc.Docs
+			walk(doc.Children)
+		}
+	}
+
+	for file := range p.files {
+		if fileDocs, err := p.Documentation(file); err == nil {
+			walk(fileDocs)
+		}
+	}
+
+	return p.symbols.index(docs), err
+}
+
+// symbolRecorder watches a compilation for the declarations and uses of
+// symbols. All of its methods do nothing on a nil recorder, which is what a
+// Parser normally has.
+type symbolRecorder struct {
+	symbols   []*Symbol
+	mixins    map[string]*Symbol
+	variables map[*variable]*Symbol
+	sites     map[string]*Symbol
+	seen      map[*Symbol]map[Position]bool
+	scopes    map[string]map[int]map[*Symbol]bool
+}
+
+func newSymbolRecorder() *symbolRecorder {
+	return &symbolRecorder{
+		mixins:    make(map[string]*Symbol),
+		variables: make(map[*variable]*Symbol),
+		sites:     make(map[string]*Symbol),
+		seen:      make(map[*Symbol]map[Position]bool),
+		scopes:    make(map[string]map[int]map[*Symbol]bool),
+	}
+}
+
+// symbol returns the symbol declared at a line, creating it the first time
+// the declaration is seen. Mixins are compiled once for each call, so the
+// same declaration is seen many times.
+func (r *symbolRecorder) symbol(kind SymbolKind, branch *scannerLine, name string, offset int) *Symbol {
+
+	key := branch.String() + " " + name
+
+	if s, ok := r.sites[key]; ok {
+		return s
+	}
+
+	s := &Symbol{
+		Kind:        kind,
+		Name:        name,
+		Declaration: Position{branch.file, branch.line, branch.column + offset},
+		Signature:   strings.SplitN(string(branch.content), "\n", 2)[0],
+	}
+
+	r.sites[key] = s
+	r.symbols = append(r.symbols, s)
+
+	return s
+}
+
+func (r *symbolRecorder) reference(s *Symbol, pos Position) {
+
+	if r.seen[s] == nil {
+		r.seen[s] = make(map[Position]bool)
+	}
+
+	if !r.seen[s][pos] {
+		r.seen[s][pos] = true
+		s.References = append(s.References, pos)
+	}
+}
+
+func (r *symbolRecorder) declareVariable(branch *scannerLine, v *variable) {
+
+	if r == nil {
+		return
+	}
+
+	r.variables[v] = r.symbol(VariableSymbol, branch, v.name, 1)
+}
+
+func (r *symbolRecorder) declareParam(mx *mixin, name string, v *variable) {
+
+	if r == nil {
+		return
+	}
+
+	offset := strings.Index(string(mx.declaration.content), "$"+name) + 1
+	r.variables[v] = r.symbol(VariableSymbol, mx.declaration, name, offset)
+}
+
+// visit records the symbols used by a line, and those in scope, before the
+// line is compiled
+func (r *symbolRecorder) visit(branch *scannerLine, tkn *tokeniser, tokens []token, scope *scope) {
+
+	if r == nil {
+		return
+	}
+
+	switch tokens[0].kind {
+
+	case tokenLineComment, tokenCommentStart, tokenCommentEnd:
+		return
+
+	case tokenMixinDeclaration:
+		r.mixins[branch.String()] = r.symbol(MixinSymbol, branch, tokens[0].content, 1)
+
+	case tokenFunctionCall:
+		if mx, ok := scope.mixins[tokens[0].content]; ok {
+			if s, ok := r.mixins[mx.declaration.String()]; ok {
+				r.reference(s, Position{branch.file, branch.line, branch.column})
+			}
+		}
+	}
+
+	// A mixin's signature declares its parameters, and := declares a new
+	// variable rather than using the one in scope
+	if tokens[0].kind != tokenMixinDeclaration {
+
+		code := tkn.stripComments(branch)
+
+		for n, occurrence := range variableOccurrences(code) {
+
+			if n == 0 && tokens[0].kind == tokenVariableDeclaration {
+				continue
+			}
+
+			if v := scope.declaredVariable(occurrence.name); v != nil {
+				if s, ok := r.variables[v]; ok {
+					r.reference(s, occurrence.position(branch))
+				}
+			}
+		}
+	}
+
+	if r.scopes[branch.file] == nil {
+		r.scopes[branch.file] = make(map[int]map[*Symbol]bool)
+	}
+
+	visible := r.scopes[branch.file][branch.line]
+
+	if visible == nil {
+		visible = make(map[*Symbol]bool)
+		r.scopes[branch.file][branch.line] = visible
+	}
+
+	for _, mx := range scope.mixins {
+		if s, ok := r.mixins[mx.declaration.String()]; ok {
+			visible[s] = true
+		}
+	}
+
+	for _, v := range scope.variables {
+		if s, ok := r.variables[v]; ok {
+			visible[s] = true
+		}
+	}
+}
+
+func (r *symbolRecorder) index(docs map[string]string) *Index {
+
+	if r == nil {
+		return nil
+	}
+
+	for reference, s := range r.mixins {
+		s.Docs = docs[reference]
+	}
+
+	for _, s := range r.symbols {
+		sort.Slice(s.References, func(a, b int) bool {
+			return positionLess(s.References[a], s.References[b])
+		})
+	}
+
+	sort.SliceStable(r.symbols, func(a, b int) bool {
+		return positionLess(r.symbols[a].Declaration, r.symbols[b].Declaration)
+	})
+
+	return &Index{Symbols: r.symbols, scopes: r.scopes}
+}
+
+// variableOccurrence is a variable named in a line, at an offset from the
+// start of the line's content
+type variableOccurrence struct {
+	name   string
+	offset int
+}
+
+// position returns the position of an occurrence, which may be on a later
+// line of a heredoc
+func (o variableOccurrence) position(branch *scannerLine) Position {
+
+	before := string(branch.content[:o.offset])
+
+	if newline := strings.LastIndex(before, "\n"); newline >= 0 {
+		return Position{branch.file, branch.line + strings.Count(before, "\n"), o.offset - newline - 1}
+	}
+
+	return Position{branch.file, branch.line, branch.column + o.offset}
+}
+
+// variableOccurrences finds the variables named in a line, following the
+// same escaping rules as interpolation: a backslash escapes the next
+// character, $$ is a dollar sign and backticks quote a literal
+func variableOccurrences(code string) (occurrences []variableOccurrence) {
+
+	isVariableChar := func(c byte) bool {
+		return unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || c == '_'
+	}
+
+	for i := 0; i < len(code); i++ {
+
+		switch code[i] {
+
+		case '\\':
+			i++
+
+		case '`':
+			if end := strings.IndexByte(code[i+1:], '`'); end >= 0 {
+				i += end + 1
+			} else {
+				return
+			}
+
+		case '$':
+
+			start := i + 1
+
+			if start < len(code) && code[start] == '$' {
+				i++
+				continue
+			}
+
+			if start < len(code) && code[start] == '{' {
+				start++
+			}
+
+			end := start
+
+			for end < len(code) && isVariableChar(code[end]) {
+				end++
+			}
+
+			if end > start {
+				occurrences = append(occurrences, variableOccurrence{code[start:end], start})
+			}
+
+			i = end - 1
+		}
+	}
+
+	return
+}
@@ -0,0 +1,132 @@
+package scl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AnIndexerFindsSymbolsAcrossIncludes(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+
+	fs.set("main.scl", `include("lib.scl")
+
+$name = "a"
+
+block
+    $name := "b"
+    greet($name)
+
+greet(${name})`, time.Now())
+
+	fs.set("lib.scl", `/*
+  Says hello
+*/
+@greet($who, $greeting = "hello")
+    message = "$greeting $who"`, time.Now())
+
+	indexer, err := NewIndexer(fs)
+	require.NoError(t, err)
+
+	index, err := indexer.Index("main.scl")
+	require.NoError(t, err)
+
+	signature := `@greet($who, $greeting = "hello")`
+
+	require.Equal(t, []*Symbol{
+		{
+			Kind:        MixinSymbol,
+			Name:        "greet",
+			Declaration: Position{"lib.scl", 4, 1},
+			Signature:   signature,
+			Docs:        "Says hello",
+			References:  []Position{{"main.scl", 7, 4}, {"main.scl", 9, 0}},
+		},
+		{
+			Kind:        VariableSymbol,
+			Name:        "who",
+			Declaration: Position{"lib.scl", 4, 8},
+			Signature:   signature,
+			References:  []Position{{"lib.scl", 5, 26}},
+		},
+		{
+			Kind:        VariableSymbol,
+			Name:        "greeting",
+			Declaration: Position{"lib.scl", 4, 14},
+			Signature:   signature,
+			References:  []Position{{"lib.scl", 5, 16}},
+		},
+		{
+			Kind:        VariableSymbol,
+			Name:        "name",
+			Declaration: Position{"main.scl", 3, 1},
+			Signature:   `$name = "a"`,
+			References:  []Position{{"main.scl", 9, 8}},
+		},
+		{
+			Kind:        VariableSymbol,
+			Name:        "name",
+			Declaration: Position{"main.scl", 6, 5},
+			Signature:   `$name := "b"`,
+			References:  []Position{{"main.scl", 7, 11}},
+		},
+	}, index.Symbols)
+
+	for cycle, test := range []struct {
+		line, column int
+		expected     *Symbol
+	}{
+		{line: 9, column: 0, expected: index.Symbols[0]},
+		{line: 9, column: 7, expected: index.Symbols[3]},
+		{line: 7, column: 12, expected: index.Symbols[4]},
+		{line: 6, column: 4, expected: index.Symbols[4]},
+		{line: 5, column: 0, expected: nil},
+	} {
+		t.Logf("Cycle %d", cycle)
+		require.Equal(t, test.expected, index.At("main.scl", test.line, test.column))
+	}
+
+	require.Equal(t, []*Symbol{index.Symbols[0], index.Symbols[4]}, index.Visible("main.scl", 7))
+	require.Equal(t, []*Symbol{index.Symbols[0], index.Symbols[3]}, index.Visible("main.scl", 9))
+}
+
+func Test_AnIndexerFindsVariablesInHeredocs(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+
+	fs.set("main.scl", `$a = 1
+text = <<EOT
+  value $a and $${a} and \$a
+  ${a}
+EOT`, time.Now())
+
+	indexer, err := NewIndexer(fs)
+	require.NoError(t, err)
+
+	index, err := indexer.Index("main.scl")
+	require.NoError(t, err)
+
+	require.Len(t, index.Symbols, 1)
+	require.Equal(t, []Position{{"main.scl", 3, 9}, {"main.scl", 4, 4}}, index.Symbols[0].References)
+}
+
+func Test_AnIndexerFindsSymbolsUsedByTests(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+
+	fs.set("main.scl", `$expected = 80
+
+@test "port"
+    @assert $expected == 80`, time.Now())
+
+	indexer, err := NewIndexer(fs)
+	require.NoError(t, err)
+
+	index, err := indexer.Index("main.scl")
+	require.NoError(t, err)
+
+	require.Len(t, index.Symbols, 1)
+	require.Equal(t, []Position{{"main.scl", 4, 13}}, index.Symbols[0].References)
+}
@@ -0,0 +1,312 @@
+package scl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+var assertComparisonMatcher = regexp.MustCompile(`^(.+?)\s+(==|!=)\s+(.+)$`)
+
+// A TestFailure is a failed @assert, or an error that stopped a @test block.
+// Differences are set when the HCL a mixin call produced wasn't as expected.
+type TestFailure struct {
+	Message     string
+	Differences []string
+}
+
+// A TestCase is the result of running a @test block
+type TestCase struct {
+	Name      string
+	Reference string
+	Failures  []TestFailure
+}
+
+// Passed returns whether every assertion in the block held
+func (t TestCase) Passed() bool {
+	return len(t.Failures) == 0
+}
+
+// String describes a failure, with its differences on the lines after it
+func (f TestFailure) String() string {
+	return strings.Join(append([]string{f.Message}, f.Differences...), "\n    ")
+}
+
+// String describes a test case and its failures
+func (t TestCase) String() string {
+
+	if t.Passed() {
+		return fmt.Sprintf("ok   %s (%s)", t.Name, t.Reference)
+	}
+
+	lines := []string{fmt.Sprintf("FAIL %s (%s)", t.Name, t.Reference)}
+
+	for _, failure := range t.Failures {
+		lines = append(lines, "  "+failure.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// TestCases is a list of test cases, in the order they appear in the file
+type TestCases []TestCase
+
+/*
+A TestRunner runs the @test blocks in an SCL file. A @test block is given a
+name, and holds SCL that's compiled only when the file's tests are run:
+
+	@test "a web service listens on port 80"
+	    $name = "web"
+	    @assert $name == "web"
+	    @assert service($name)
+	        service "web"
+	            port = 80
+
+An @assert either compares two values, with == or !=, after interpolating any
+variables in them and removing their quotes; or calls a mixin and compares the
+HCL it produces with the block under the @assert, which is compiled like any
+other SCL. The HCL is compared by its structure, as CompareHCL does. A failed assertion doesn't
+stop the rest of the block, but an error does.
+
+The file is compiled as normal first, and the blocks are run afterwards in
+their own scopes, so they can use every mixin and variable the file declares
+at the top level. Their output never becomes part of the file's output.
+Only the @test blocks in the file given to Run() are run, and not those in
+the files it includes.
+*/
+type TestRunner interface {
+	SetParam(name, value string)
+	AddIncludePath(name string)
+	Run(fileName string) (TestCases, error)
+}
+
+type testRunner struct {
+	fs           FileSystem
+	params       [][2]string
+	includePaths []string
+}
+
+/*
+NewTestRunner creates a TestRunner that reads files using the FileSystem
+provided, in the same way as a Parser.
+*/
+func NewTestRunner(fs FileSystem) (TestRunner, error) {
+	return &testRunner{fs: fs}, nil
+}
+
+func (r *testRunner) SetParam(name, value string) {
+	r.params = append(r.params, [2]string{name, value})
+}
+
+func (r *testRunner) AddIncludePath(name string) {
+	r.includePaths = append(r.includePaths, name)
+}
+
+/*
+Run compiles a file and then runs its @test blocks. An error is returned only
+if the file itself can't be compiled; errors inside a @test block are
+failures of that block.
+*/
+func (r *testRunner) Run(fileName string) (TestCases, error) {
+
+	p0, err := NewParser(r.fs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := p0.(*parser)
+	p.tests = &testRecorder{fileName: fileName}
+
+	for _, param := range r.params {
+		p.SetParam(param[0], param[1])
+	}
+
+	for _, includePath := range r.includePaths {
+		p.AddIncludePath(includePath)
+	}
+
+	if err := p.Parse(fileName); err != nil {
+		return nil, err
+	}
+
+	return p.tests.run(p), nil
+}
+
+// testRecorder collects the @test blocks in a file while it's compiled, and
+// the results of the one that's running. Without a file name, it collects
+// the blocks in every file.
+type testRecorder struct {
+	fileName string
+	blocks   []*scannerLine
+	names    []string
+	current  *TestCase
+}
+
+func (r *testRecorder) add(branch *scannerLine, name string) {
+
+	if r == nil || (r.fileName != "" && branch.file != r.fileName) {
+		return
+	}
+
+	r.blocks = append(r.blocks, branch)
+	r.names = append(r.names, name)
+}
+
+func (r *testRecorder) running() bool {
+	return r != nil && r.current != nil
+}
+
+func (r *testRecorder) fail(message string, differences []string) {
+	r.current.Failures = append(r.current.Failures, TestFailure{Message: message, Differences: differences})
+}
+
+func (r *testRecorder) run(p *parser) TestCases {
+
+	cases := TestCases{}
+
+	for i, branch := range r.blocks {
+
+		r.current = &TestCase{Name: r.names[i], Reference: branch.String(), Failures: []TestFailure{}}
+
+		_, err := p.isolatedOutput(func() error {
+			return p.parseTree(branch.children, newTokeniser(), p.rootScope.clone())
+		})
+
+		if err != nil {
+			r.fail(err.Error(), nil)
+		}
+
+		cases = append(cases, *r.current)
+		r.current = nil
+	}
+
+	return cases
+}
+
+// isolatedOutput compiles SCL without adding to the parser's output, and
+// returns the HCL it produced instead
+func (p *parser) isolatedOutput(compile func() error) (string, error) {
+
+	output, indent, tree, lists := p.output, p.indent, p.ast, p.lists
+
+	root := &ast.ObjectList{}
+	p.output, p.indent = nil, 0
+	p.ast, p.lists = &ast.File{Node: root}, []*ast.ObjectList{root}
+
+	err := compile()
+	result := strings.Join(p.output, "\n")
+
+	p.output, p.indent, p.ast, p.lists = output, indent, tree, lists
+
+	return result, err
+}
+
+func (p *parser) parseTestDirective(branch *scannerLine, arguments string, scope *scope) error {
+
+	name := strings.Trim(arguments, `"'`)
+
+	if name == "" {
+		return p.err(branch, `@test needs a name, such as @test "name"`)
+	}
+
+	if scope != p.rootScope {
+		return p.err(branch, "@test blocks must be at the top level of a file")
+	}
+
+	// The block is only compiled when the tests are run
+	p.tests.add(branch, name)
+
+	return nil
+}
+
+func (p *parser) parseAssertDirective(branch *scannerLine, tkn *tokeniser, arguments string, scope *scope) error {
+
+	if !p.tests.running() {
+		return p.err(branch, "@assert can only be used in a @test block")
+	}
+
+	matches := assertComparisonMatcher.FindStringSubmatch(arguments)
+
+	if matches == nil {
+
+		if functionMatcher.MatchString(arguments) {
+			return p.assertMixinOutput(branch, tkn, arguments, scope)
+		}
+
+		return p.err(branch, `@assert needs a comparison, such as $name == "value", or a mixin call`)
+	}
+
+	left, err := scope.interpolateLiteral(matches[1])
+
+	if err != nil {
+		return p.err(branch, err.Error())
+	}
+
+	right, err := scope.interpolateLiteral(matches[3])
+
+	if err != nil {
+		return p.err(branch, err.Error())
+	}
+
+	// Either kind of quotes will do, and a quoted number is still a number
+	equal := unquote(left) == unquote(right)
+
+	switch {
+	case matches[2] == "==" && !equal:
+		p.tests.fail(p.err(branch, "%s failed: %s != %s", arguments, left, right).Error(), nil)
+
+	case matches[2] == "!=" && equal:
+		p.tests.fail(p.err(branch, "%s failed: both are %s", arguments, left).Error(), nil)
+	}
+
+	return nil
+}
+
+// assertMixinOutput compares the output of a mixin call with the HCL in the
+// block under the @assert, which is compiled as SCL
+func (p *parser) assertMixinOutput(branch *scannerLine, tkn *tokeniser, call string, scope *scope) error {
+
+	if len(branch.children) == 0 {
+		return p.err(branch, "@assert %s needs the HCL it should produce in a block under it", call)
+	}
+
+	tokens, err := tkn.tokeniseFunctionCall(branch, lineContent(call))
+
+	if err != nil {
+		return p.err(branch, err.Error())
+	}
+
+	// The expected HCL isn't a body for the mixin
+	line := newLine(branch.file, branch.line, branch.column, call)
+
+	actual, err := p.isolatedOutput(func() error {
+		return p.parseFunctionCall(line, tkn, tokens, scope.clone())
+	})
+
+	if err != nil {
+		return err
+	}
+
+	expected, err := p.isolatedOutput(func() error {
+		return p.parseTree(branch.children, tkn, scope.clone())
+	})
+
+	if err != nil {
+		return err
+	}
+
+	differences, err := CompareHCL([]byte(expected), []byte(actual))
+
+	if err != nil {
+		return p.err(branch, err.Error())
+	}
+
+	if len(differences) > 0 {
+		p.tests.fail(p.err(branch, "%s produced different HCL (expected != actual):", call).Error(), differences)
+	}
+
+	return nil
+}
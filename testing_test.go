@@ -0,0 +1,186 @@
+package scl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TestBlocksAreIgnoredByTheParser(t *testing.T) {
+
+	p := newMockParser(t)
+
+	require.NoError(t, p.Parse("fixtures/valid/tests.scl"))
+	require.Equal(t, `service "web" {
+  port = 80
+  environment = "production"
+}`, p.String())
+}
+
+func Test_ATestRunnerCanRunTestBlocks(t *testing.T) {
+
+	runner, err := NewTestRunner(NewDiskSystem())
+	require.NoError(t, err)
+
+	cases, err := runner.Run("fixtures/valid/tests.scl")
+	require.NoError(t, err)
+
+	require.Equal(t, TestCases{
+		{Name: "services use the environment", Reference: "fixtures/valid/tests.scl:10", Failures: []TestFailure{}},
+		{Name: "the default port is 80", Reference: "fixtures/valid/tests.scl:17", Failures: []TestFailure{}},
+	}, cases)
+}
+
+func Test_ATestRunnerReportsFailedAssertions(t *testing.T) {
+
+	for cycle, test := range []struct {
+		files    map[string]string
+		expected TestCases
+	}{
+		{
+			files: map[string]string{
+				"main.scl": `$a = 1
+
+@test "comparisons"
+    @assert $a == 2
+    @assert $a != 1
+    @assert $a == 1
+    @assert ${a}0 == 10`,
+			},
+			expected: TestCases{
+				{
+					Name:      "comparisons",
+					Reference: "main.scl:3",
+					Failures: []TestFailure{
+						{Message: "[main.scl:4] $a == 2 failed: 1 != 2"},
+						{Message: "[main.scl:5] $a != 1 failed: both are 1"},
+					},
+				},
+			},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `$name = 'web'
+$port = "80"
+
+@test "quotes"
+    @assert $name == "web"
+    @assert $port == 80
+    @assert $name != "web"
+    @assert $name != 'api'`,
+			},
+			expected: TestCases{
+				{
+					Name:      "quotes",
+					Reference: "main.scl:4",
+					Failures: []TestFailure{
+						{Message: `[main.scl:7] $name != "web" failed: both are 'web'`},
+					},
+				},
+			},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `@m($port)
+    service
+        port = $port
+        __body__()
+
+@test "mixin output"
+    @assert m(80)
+        service {
+            port = 8080
+        }
+    @assert m(80)
+        service {
+            port = 80
+        }
+
+@test "errors stop a block"
+    missing()
+    @assert 1 == 2`,
+			},
+			expected: TestCases{
+				{
+					Name:      "mixin output",
+					Reference: "main.scl:6",
+					Failures: []TestFailure{
+						{
+							Message:     "[main.scl:7] m(80) produced different HCL (expected != actual):",
+							Differences: []string{"service.port: 8080 != 80"},
+						},
+					},
+				},
+				{
+					Name:      "errors stop a block",
+					Reference: "main.scl:16",
+					Failures: []TestFailure{
+						{Message: "[main.scl:17] Mixin missing not declared in this scope"},
+					},
+				},
+			},
+		},
+		{
+			files: map[string]string{
+				"main.scl": `include "lib.scl"
+
+@test "only in the file run"
+    @assert 1 == 1`,
+				"lib.scl": `@test "in an include"
+    @assert 1 == 2`,
+			},
+			expected: TestCases{
+				{Name: "only in the file run", Reference: "main.scl:3", Failures: []TestFailure{}},
+			},
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		fs := newMemoryFileSystem()
+
+		for name, content := range test.files {
+			fs.set(name, content, time.Now())
+		}
+
+		runner, err := NewTestRunner(fs)
+		require.NoError(t, err)
+
+		cases, err := runner.Run("main.scl")
+		require.NoError(t, err)
+		require.Equal(t, test.expected, cases)
+	}
+}
+
+func Test_TestDirectivesAreCheckedWhenParsing(t *testing.T) {
+
+	for cycle, test := range []struct {
+		source string
+		err    string
+	}{
+		{
+			source: `@assert 1 == 1`,
+			err:    "[main.scl:1] @assert can only be used in a @test block",
+		},
+		{
+			source: `@test`,
+			err:    `[main.scl:1] @test needs a name, such as @test "name"`,
+		},
+		{
+			source: "block\n    @test \"nested\"\n        a = 1",
+			err:    "[main.scl:2] @test blocks must be at the top level of a file",
+		},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		fs := newMemoryFileSystem()
+		fs.set("main.scl", test.source, time.Now())
+
+		p, err := NewParser(fs)
+		require.NoError(t, err)
+
+		err = p.Parse("main.scl")
+		require.Error(t, err)
+		require.Equal(t, test.err, err.Error())
+	}
+}
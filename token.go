@@ -14,6 +14,8 @@ const (
 	tokenConditionalVariableAssignment
 	tokenCommentStart
 	tokenCommentEnd
+	tokenDirective
+	tokenParamType
 )
 
 var tokenKindsByString = map[tokenKind]string{
@@ -26,6 +28,8 @@ var tokenKindsByString = map[tokenKind]string{
 	tokenLiteral:                       "literal",
 	tokenCommentStart:                  "comment start",
 	tokenCommentEnd:                    "comment end",
+	tokenDirective:                     "directive",
+	tokenParamType:                     "parameter type",
 }
 
 type token struct {
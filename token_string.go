@@ -4,9 +4,9 @@ package scl
 
 import "fmt"
 
-const _tokenKind_name = "tokenLineCommenttokenMixinDeclarationtokenVariabletokenVariableAssignmenttokenFunctionCalltokenLiteraltokenVariableDeclarationtokenConditionalVariableAssignmenttokenCommentStarttokenCommentEnd"
+const _tokenKind_name = "tokenLineCommenttokenMixinDeclarationtokenVariabletokenVariableAssignmenttokenFunctionCalltokenLiteraltokenVariableDeclarationtokenConditionalVariableAssignmenttokenCommentStarttokenCommentEndtokenDirectivetokenParamType"
 
-var _tokenKind_index = [...]uint8{0, 16, 37, 50, 73, 90, 102, 126, 160, 177, 192}
+var _tokenKind_index = [...]uint8{0, 16, 37, 50, 73, 90, 102, 126, 160, 177, 192, 206, 220}
 
 func (i tokenKind) String() string {
 	if i < 0 || i >= tokenKind(len(_tokenKind_index)-1) {
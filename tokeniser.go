@@ -11,11 +11,13 @@ var hashCommentMatcher = regexp.MustCompile(`#.+$`)
 var functionMatcher = regexp.MustCompile(`^([a-zA-Z0-9_]+)\s?\((.*)\):?$`)
 var shortFunctionMatcher = regexp.MustCompile(`^([a-zA-Z0-9_]+):$`)
 var variableMatcher = regexp.MustCompile(`^\$([a-zA-Z_][a-zA-Z0-9_]*)$`)
+var typedArgumentMatcher = regexp.MustCompile(`^\$([a-zA-Z_][a-zA-Z0-9_]*)\s*:\s*([a-z]+|(?:"[^"]*"|'[^']*')(?:\s*\|\s*(?:"[^"]*"|'[^']*'))*)(?:\s*=\s*(.+))?$`)
 var assignmentMatcher = regexp.MustCompile(`^\$([a-zA-Z_][a-zA-Z0-9_]*)\s*=\s*((.|\n)+)$`)
 var declarationMatcher = regexp.MustCompile(`^\$([a-zA-Z_][a-zA-Z0-9_]*)\s*:=\s*(.+)$`)
 var conditionalVariableMatcher = regexp.MustCompile(`^\$([a-zA-Z_0-9]+)\s*\?=\s*(.+)$`)
 var docblockStartMatcher = regexp.MustCompile(`^/\*$`)
 var docblockEndMatcher = regexp.MustCompile(`^\*\/$`)
+var directiveMatcher = regexp.MustCompile(`^@(assert|debug|error|param|test|warn)(?:\s+([^\s(].*))?$`)
 var heredocMatcher = regexp.MustCompile(`<<([a-zA-Z]+)\s*$`)
 
 type tokeniser struct {
@@ -95,7 +97,11 @@ func (t *tokeniser) tokenise(l *scannerLine) (tokens []token, err error) {
 		return t.tokeniseCommentEnd(l, lineContent(content))
 	}
 
-	// Mixin declarations start with a @
+	// Directives and mixin declarations start with a @
+	if directiveMatcher.MatchString(content) {
+		return t.tokeniseDirective(l, lineContent(content))
+	}
+
 	if content[0] == '@' {
 		return t.tokeniseMixinDeclaration(l, lineContent(content))
 	}
@@ -183,6 +189,17 @@ func (t *tokeniser) tokeniseFunction(l *scannerLine, input string) (name string,
 
 			if matches := variableMatcher.FindStringSubmatch(arg); len(matches) > 1 {
 				tokens = append(tokens, token{kind: tokenVariable, content: matches[1], line: l})
+			} else if matches := typedArgumentMatcher.FindStringSubmatch(arg); len(matches) > 1 {
+
+				// The type follows the argument and its default
+				if matches[3] == "" {
+					tokens = append(tokens, token{kind: tokenVariable, content: matches[1], line: l})
+				} else {
+					tokens = append(tokens, token{kind: tokenVariableAssignment, content: matches[1], line: l})
+					tokens = append(tokens, token{kind: tokenLiteral, content: matches[3], line: l})
+				}
+
+				tokens = append(tokens, token{kind: tokenParamType, content: matches[2], line: l})
 			} else if matches := assignmentMatcher.FindStringSubmatch(arg); len(matches) > 1 {
 				tokens = append(tokens, token{kind: tokenVariableAssignment, content: matches[1], line: l})
 				tokens = append(tokens, token{kind: tokenLiteral, content: matches[2], line: l})
@@ -209,6 +226,24 @@ func (t *tokeniser) tokeniseMixinDeclaration(l *scannerLine, content lineContent
 	return
 }
 
+func (t *tokeniser) tokeniseDirective(l *scannerLine, content lineContent) (tokens []token, err error) {
+
+	parts := directiveMatcher.FindStringSubmatch(string(content))
+
+	if len(parts) > 0 {
+
+		tokens = append(tokens, token{kind: tokenDirective, content: parts[1], line: l})
+
+		if parts[2] != "" {
+			tokens = append(tokens, token{kind: tokenLiteral, content: parts[2], line: l})
+		}
+
+		return tokens, nil
+	}
+
+	return tokens, fmt.Errorf("Failed to parse directive")
+}
+
 func (t *tokeniser) tokeniseFunctionCall(l *scannerLine, content lineContent) (tokens []token, err error) {
 
 	name, fntokens, fnerr := t.tokeniseFunction(l, string(content))
@@ -264,6 +264,9 @@ func Test_ATokeniserCanReadTokensFromAValidScannerLine(t *testing.T) {
 	var functionCallLine1 = newLine("test.scl", 1, 0, `fn($a,"123")`)
 	var shortFunctionCallLine1 = newLine("test.scl", 1, 0, `fn:`)
 	var assignmentLine = newLine("test.scl", 1, 0, `$a = "123"`)
+	var directiveLine1 = newLine("test.scl", 1, 0, `@test "name"`)
+	var directiveLine2 = newLine("test.scl", 1, 0, `@test($a)`)
+	var typedDeclarationLine = newLine("test.scl", 1, 0, `@m($a: number, $b: "x"|"y" = "x")`)
 
 	for cycle, input := range []struct {
 		line   *scannerLine
@@ -399,6 +402,47 @@ func Test_ATokeniserCanReadTokensFromAValidScannerLine(t *testing.T) {
 				},
 			},
 		},
+		{
+			line: directiveLine1,
+			tokens: []token{
+				token{
+					kind:    tokenDirective,
+					content: "test",
+					line:    directiveLine1,
+				},
+				{
+					kind:    tokenLiteral,
+					content: `"name"`,
+					line:    directiveLine1,
+				},
+			},
+		},
+		{
+			line: directiveLine2,
+			tokens: []token{
+				token{
+					kind:    tokenMixinDeclaration,
+					content: "test",
+					line:    directiveLine2,
+				},
+				{
+					kind:    tokenVariable,
+					content: "a",
+					line:    directiveLine2,
+				},
+			},
+		},
+		{
+			line: typedDeclarationLine,
+			tokens: []token{
+				{kind: tokenMixinDeclaration, content: "m", line: typedDeclarationLine},
+				{kind: tokenVariable, content: "a", line: typedDeclarationLine},
+				{kind: tokenParamType, content: "number", line: typedDeclarationLine},
+				{kind: tokenVariableAssignment, content: "b", line: typedDeclarationLine},
+				{kind: tokenLiteral, content: `"x"`, line: typedDeclarationLine},
+				{kind: tokenParamType, content: `"x"|"y"`, line: typedDeclarationLine},
+			},
+		},
 	} {
 		t.Logf("Cycle %d", cycle)
 
@@ -0,0 +1,270 @@
+package scl
+
+import (
+	"strings"
+)
+
+/*
+A Call is an invocation of a mixin, or of __body__(), recorded by a Tracer.
+Declaration is the declaration that the call resolved to, which for
+__body__() is the call whose body ran. Scope is the call during which that
+declaration was made, or nil if it was made in the root scope; a mixin
+declared in the body passed to another mixin overrides the other mixin's
+declarations of the same name.
+
+FirstLine and LastLine are the lines of the HCL output that the call
+produced, counting from one, including the lines produced by the calls it
+made. They're both zero if the call produced nothing.
+*/
+type Call struct {
+	Mixin       string
+	Reference   string
+	Declaration string
+	Scope       *Call
+	Arguments   []CallArgument
+	FirstLine   int
+	LastLine    int
+	Children    Calls
+}
+
+// A CallArgument is the value a mixin parameter had for a call
+type CallArgument struct {
+	Name  string
+	Value string
+}
+
+// Calls is a slice of calls, in the order they were made
+type Calls []*Call
+
+/*
+Within returns the calls that produced any of the output from the first line
+to the last, with their children filtered in the same way.
+*/
+func (c Calls) Within(first, last int) Calls {
+
+	calls := Calls{}
+
+	for _, call := range c {
+
+		if call.FirstLine == 0 || call.LastLine < first || call.FirstLine > last {
+			continue
+		}
+
+		filtered := *call
+		filtered.Children = call.Children.Within(first, last)
+		calls = append(calls, &filtered)
+	}
+
+	return calls
+}
+
+// A Trace is the calls made while compiling a file, and the output they made
+type Trace struct {
+	Calls  Calls
+	Output []string
+}
+
+/*
+Block finds a block in the output by its header, such as `model "name"`, and
+returns its first and last lines. Any whitespace in the header matches any
+whitespace in the output.
+*/
+func (t *Trace) Block(header string) (first, last int, ok bool) {
+
+	header = strings.Join(strings.Fields(header), " ")
+
+	for i, line := range t.Output {
+
+		trimmed := strings.Join(strings.Fields(line), " ")
+
+		if trimmed != header+" {" {
+			continue
+		}
+
+		indentation := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+		for j := i + 1; j < len(t.Output); j++ {
+			if t.Output[j] == indentation+"}" {
+				return i + 1, j + 1, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+/*
+A Tracer compiles SCL files and records every mixin call, to explain how the
+output came about.
+*/
+type Tracer interface {
+	SetParam(name, value string)
+	AddIncludePath(name string)
+	Trace(fileName string) (*Trace, error)
+}
+
+type tracer struct {
+	fs           FileSystem
+	params       [][2]string
+	includePaths []string
+}
+
+/*
+NewTracer creates a Tracer that reads files using the FileSystem provided, in
+the same way as a Parser.
+*/
+func NewTracer(fs FileSystem) (Tracer, error) {
+	return &tracer{fs: fs}, nil
+}
+
+func (t *tracer) SetParam(name, value string) {
+	t.params = append(t.params, [2]string{name, value})
+}
+
+func (t *tracer) AddIncludePath(name string) {
+	t.includePaths = append(t.includePaths, name)
+}
+
+/*
+Trace compiles a file and returns the calls it made. If the file can't be
+compiled, the calls made before the error are returned along with it.
+*/
+func (t *tracer) Trace(fileName string) (*Trace, error) {
+
+	p0, err := NewParser(t.fs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := p0.(*parser)
+	p.trace = newTraceRecorder()
+
+	for _, param := range t.params {
+		p.SetParam(param[0], param[1])
+	}
+
+	for _, includePath := range t.includePaths {
+		p.AddIncludePath(includePath)
+	}
+
+	err = p.Parse(fileName)
+
+	return p.trace.result(p), err
+}
+
+// traceRecorder builds the tree of calls as a compilation makes them. All of
+// its methods do nothing on a nil recorder, which is what a Parser normally
+// has.
+type traceRecorder struct {
+	calls    Calls
+	stack    []*Call
+	scopes   map[*mixin]*Call
+	outputAt map[*Call][2]int
+}
+
+func newTraceRecorder() *traceRecorder {
+	return &traceRecorder{
+		scopes:   make(map[*mixin]*Call),
+		outputAt: make(map[*Call][2]int),
+	}
+}
+
+func (r *traceRecorder) current() *Call {
+
+	if len(r.stack) == 0 {
+		return nil
+	}
+
+	return r.stack[len(r.stack)-1]
+}
+
+func (r *traceRecorder) declareMixin(mx *mixin) {
+
+	if r == nil {
+		return
+	}
+
+	r.scopes[mx] = r.current()
+}
+
+// startCall records a call that's about to compile, given the number of
+// output lines before it
+func (r *traceRecorder) startCall(branch *scannerLine, name string, declaration *scannerLine, mx *mixin, args []string, output int) {
+
+	if r == nil {
+		return
+	}
+
+	call := &Call{
+		Mixin:       name,
+		Reference:   branch.String(),
+		Declaration: declaration.String(),
+	}
+
+	if mx != nil {
+
+		call.Scope = r.scopes[mx]
+
+		for i, argument := range mx.arguments {
+			call.Arguments = append(call.Arguments, CallArgument{argument.name, args[i]})
+		}
+	}
+
+	if parent := r.current(); parent != nil {
+		parent.Children = append(parent.Children, call)
+	} else {
+		r.calls = append(r.calls, call)
+	}
+
+	r.stack = append(r.stack, call)
+	r.outputAt[call] = [2]int{output, output}
+}
+
+// endCall records the end of the innermost call, given the number of output
+// lines after it
+func (r *traceRecorder) endCall(output int) {
+
+	if r == nil || len(r.stack) == 0 {
+		return
+	}
+
+	call := r.current()
+	r.outputAt[call] = [2]int{r.outputAt[call][0], output}
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+func (r *traceRecorder) result(p *parser) *Trace {
+
+	if r == nil {
+		return nil
+	}
+
+	// Calls that were still running when an error stopped the compilation
+	// produced everything up to the error
+	for len(r.stack) > 0 {
+		r.endCall(len(p.output))
+	}
+
+	// Each entry in the output can be more than one line
+	lineAt := make([]int, len(p.output)+1)
+	lineAt[0] = 1
+
+	for i, entry := range p.output {
+		lineAt[i+1] = lineAt[i] + strings.Count(entry, "\n") + 1
+	}
+
+	for call, at := range r.outputAt {
+		if at[1] > at[0] {
+			call.FirstLine, call.LastLine = lineAt[at[0]], lineAt[at[1]]-1
+		}
+	}
+
+	output := []string{}
+
+	if len(p.output) > 0 {
+		output = strings.Split(strings.Join(p.output, "\n"), "\n")
+	}
+
+	return &Trace{Calls: r.calls, Output: output}
+}
@@ -0,0 +1,100 @@
+package scl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ATracerRecordsTheCallTree(t *testing.T) {
+
+	tracer, err := NewTracer(NewDiskSystem())
+	require.NoError(t, err)
+
+	trace, err := tracer.Trace("fixtures/valid/callback.scl")
+	require.NoError(t, err)
+	require.Len(t, trace.Calls, 2)
+
+	extendedBase := trace.Calls[0]
+	require.Equal(t, "extendedBase", extendedBase.Mixin)
+	require.Equal(t, "fixtures/valid/callback.scl:22", extendedBase.Reference)
+	require.Equal(t, "fixtures/valid/callback.scl:12", extendedBase.Declaration)
+	require.Equal(t, []CallArgument{{"myVar", "1"}}, extendedBase.Arguments)
+	require.Nil(t, extendedBase.Scope)
+	require.Equal(t, 1, extendedBase.FirstLine)
+	require.Equal(t, 3, extendedBase.LastLine)
+
+	base := extendedBase.Children[0]
+	require.Equal(t, "base", base.Mixin)
+	require.Equal(t, []CallArgument{{"var", "1"}}, base.Arguments)
+
+	body := base.Children[0]
+	require.Equal(t, "__body__", body.Mixin)
+	require.Equal(t, "fixtures/valid/callback.scl:13", body.Declaration)
+	require.Len(t, body.Children, 2)
+
+	fn2, overloadable := body.Children[0], body.Children[1]
+
+	require.Equal(t, base, fn2.Scope)
+	require.Equal(t, 1, fn2.FirstLine)
+	require.Equal(t, 1, fn2.LastLine)
+
+	// The overload declared in the body of base() wins over base's own
+	require.Equal(t, "fixtures/valid/callback.scl:14", overloadable.Declaration)
+	require.Equal(t, body, overloadable.Scope)
+	require.Equal(t, `base = "this is from the overloader"`, trace.Output[overloadable.FirstLine-1])
+}
+
+func Test_ATraceCanBeFilteredToABlock(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", `@leaf($v)
+    value = $v
+
+@wrap($name)
+    block $name
+        __body__()
+
+wrap("a")
+    leaf(1)
+wrap("b")
+    leaf(2)
+    leaf(3)`, time.Now())
+
+	tracer, err := NewTracer(fs)
+	require.NoError(t, err)
+
+	trace, err := tracer.Trace("main.scl")
+	require.NoError(t, err)
+
+	for cycle, test := range []struct {
+		header      string
+		first, last int
+		ok          bool
+	}{
+		{header: `block "a"`, first: 1, last: 3, ok: true},
+		{header: `block    "b"`, first: 4, last: 7, ok: true},
+		{header: `block "c"`},
+	} {
+		t.Logf("Cycle %d", cycle)
+
+		first, last, ok := trace.Block(test.header)
+
+		require.Equal(t, test.ok, ok)
+		require.Equal(t, test.first, first)
+		require.Equal(t, test.last, last)
+	}
+
+	first, last, _ := trace.Block(`block "b"`)
+	calls := trace.Calls.Within(first, last)
+
+	require.Len(t, calls, 1)
+	require.Equal(t, []CallArgument{{"name", `"b"`}}, calls[0].Arguments)
+	require.Len(t, calls[0].Children[0].Children, 2)
+
+	calls = trace.Calls.Within(6, 6)
+
+	require.Len(t, calls[0].Children[0].Children, 1)
+	require.Equal(t, []CallArgument{{"v", "3"}}, calls[0].Children[0].Children[0].Arguments)
+}
@@ -0,0 +1,63 @@
+package scl
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+func (p *parser) objectList() (*ast.ObjectList, error) {
+
+	list, ok := p.ast.Node.(*ast.ObjectList)
+
+	if !ok {
+		return nil, fmt.Errorf("Unexpected root node %T", p.ast.Node)
+	}
+
+	return list, nil
+}
+
+/*
+nodeValue converts a value into a literal, or a slice of values for a list.
+Objects within it are converted by the function given, so that each output
+format can represent them in its own way.
+*/
+func nodeValue(node ast.Node, object func(list *ast.ObjectList) (interface{}, error)) (interface{}, error) {
+
+	switch n := node.(type) {
+
+	case *ast.LiteralType:
+		return n.Token.Value(), nil
+
+	case *ast.ListType:
+
+		values := []interface{}{}
+
+		for _, v := range n.List {
+
+			value, err := nodeValue(v, object)
+
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+		}
+
+		return values, nil
+
+	case *ast.ObjectType:
+		return object(n.List)
+	}
+
+	return nil, fmt.Errorf("%s: Unexpected value %T", node.Pos(), node)
+}
+
+func keyValue(key *ast.ObjectKey) string {
+
+	if s, ok := key.Token.Value().(string); ok {
+		return s
+	}
+
+	return key.Token.Text
+}
@@ -0,0 +1,135 @@
+package scl
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"gopkg.in/yaml.v2"
+)
+
+// A key is either a value, or a block with a number of labels
+const yamlValueKey = -1
+
+func (p *parser) YAML() ([]byte, error) {
+
+	list, err := p.objectList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := yamlObject(list)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty document would otherwise be written as {}
+	if len(value) == 0 {
+		return []byte{}, nil
+	}
+
+	return yaml.Marshal(value)
+}
+
+func yamlObject(list *ast.ObjectList) (yaml.MapSlice, error) {
+
+	out := yaml.MapSlice{}
+
+	index := make(map[string]int)
+	kinds := make(map[string]int)
+
+	for _, item := range list.Items {
+
+		if len(item.Keys) == 0 {
+			return nil, fmt.Errorf("%s: Missing key", item.Pos())
+		}
+
+		key := keyValue(item.Keys[0])
+		object, isObject := item.Val.(*ast.ObjectType)
+
+		kind := yamlValueKey
+
+		// An object that's assigned with = is a value rather than a block
+		if (isObject && !item.Assign.IsValid()) || len(item.Keys) > 1 {
+			kind = len(item.Keys) - 1
+		}
+
+		var (
+			value interface{}
+			err   error
+		)
+
+		if kind == yamlValueKey {
+			value, err = nodeValue(item.Val, yamlObjectValue)
+		} else if object == nil {
+			err = fmt.Errorf("%s: %s has labels but no block", item.Pos(), key)
+		} else {
+			value, err = yamlObject(object.List)
+
+			// Every block is a sequence, however many times it's repeated
+			value = []interface{}{value}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Labels become keys of nested mappings
+		for i := len(item.Keys) - 1; i > 0; i-- {
+			value = yaml.MapSlice{{Key: keyValue(item.Keys[i]), Value: value}}
+		}
+
+		i, exists := index[key]
+
+		if !exists {
+			index[key] = len(out)
+			kinds[key] = kind
+			out = append(out, yaml.MapItem{Key: key, Value: value})
+			continue
+		}
+
+		switch {
+		case (kind == yamlValueKey) != (kinds[key] == yamlValueKey):
+			return nil, fmt.Errorf("%s: %s is used for both a block and a value", item.Pos(), key)
+
+		case kind == yamlValueKey:
+			out[i].Value = value
+
+		case kind != kinds[key]:
+			return nil, fmt.Errorf("%s: %s blocks have different numbers of labels", item.Pos(), key)
+
+		default:
+			out[i].Value = yamlMergeBlock(out[i].Value, value, kind)
+		}
+	}
+
+	return out, nil
+}
+
+// yamlMergeBlock adds a block to an existing one with the same key. Blocks
+// with different labels are merged into the same mapping, and otherwise the
+// block is added to the sequence.
+func yamlMergeBlock(existing, value interface{}, labels int) interface{} {
+
+	if labels == 0 {
+		return append(existing.([]interface{}), value.([]interface{})...)
+	}
+
+	mapping := existing.(yaml.MapSlice)
+	label := value.(yaml.MapSlice)[0]
+
+	for i, item := range mapping {
+		if item.Key == label.Key {
+			mapping[i].Value = yamlMergeBlock(item.Value, label.Value, labels-1)
+			return mapping
+		}
+	}
+
+	return append(mapping, label)
+}
+
+// yamlObjectValue converts an object within a value, keeping its keys in order
+func yamlObjectValue(list *ast.ObjectList) (interface{}, error) {
+	return yamlObject(list)
+}
@@ -0,0 +1,63 @@
+package scl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AParserCanProduceYAML(t *testing.T) {
+
+	golds, err := filepath.Glob("fixtures/valid/*.yaml")
+	require.Nil(t, err)
+	require.NotEmpty(t, golds)
+
+	for _, gold := range golds {
+		t.Logf("Gold file %s", gold)
+
+		expected, err := ioutil.ReadFile(gold)
+		require.Nil(t, err)
+
+		p := newMockParser(t)
+		require.Nil(t, p.Parse(strings.TrimSuffix(gold, ".yaml")+".scl"))
+
+		yaml, err := p.YAML()
+		require.Nil(t, err)
+		require.Equal(t, string(expected), string(yaml))
+	}
+}
+
+func Test_AParserCantProduceYAMLForConflictingKeys(t *testing.T) {
+
+	p := newMockParser(t)
+	require.Nil(t, p.Parse("fixtures/valid/basic.scl"))
+
+	_, err := p.YAML()
+	require.Equal(t, fmt.Errorf("fixtures/valid/basic.scl:5:3: inner is used for both a block and a value"), err)
+}
+
+func Test_YAMLBlocksAreAlwaysSequences(t *testing.T) {
+
+	fs := newMemoryFileSystem()
+	fs.set("main.scl", "metadata = {\n    name = \"web\"\n}\nspec\n    replicas = 2\nservice \"web\"\n    port = 80", time.Now())
+
+	p, err := NewParser(fs)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse("main.scl"))
+
+	yaml, err := p.YAML()
+	require.NoError(t, err)
+	require.Equal(t, `metadata:
+  name: web
+spec:
+- replicas: 2
+service:
+  web:
+  - port: 80
+`, string(yaml))
+}